@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/w1ck3dg0ph3r/goce/compilers"
+	"github.com/w1ck3dg0ph3r/goce/pkg/cache"
+	"github.com/w1ck3dg0ph3r/goce/store"
+)
+
+// RequireAdmin authorizes requests by the admin token in the Authorization header.
+// Admin API is disabled unless the token is configured.
+func (api *API) RequireAdmin() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if api.Config.AdminToken == "" {
+			return fiber.ErrNotFound
+		}
+		if !api.IsAdmin(ctx) {
+			return fiber.ErrUnauthorized
+		}
+		return ctx.Next()
+	}
+}
+
+// IsAdmin reports whether the request is authorized with the admin token.
+func (api *API) IsAdmin(ctx *fiber.Ctx) bool {
+	if api.Config.AdminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(api.Config.AdminToken)) == 1
+}
+
+// InvalidateCompiler removes cached compilation results of the compiler.
+func (api *API) InvalidateCompiler(ctx *fiber.Ctx) error {
+	type Request struct {
+		Compiler string `json:"compiler"`
+	}
+	type Response struct {
+		Deleted int `json:"deleted"`
+	}
+
+	var req Request
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	info, err := compilers.ParseInfo(req.Compiler)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if api.CompilationCache == nil {
+		return fiber.NewError(fiber.StatusNotFound, "compilation cache is disabled")
+	}
+	deleted, err := store.InvalidateCompiler(api.CompilationCache, info.Name())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(Response{Deleted: deleted})
+}
+
+// Stats returns usage statistics of the compilation cache and shared code store.
+func (api *API) Stats(ctx *fiber.Ctx) error {
+	type Response struct {
+		CompilationCache *cache.Stats `json:"compilationCache"`
+		SharedCode       cache.Stats  `json:"sharedCode"`
+	}
+
+	var res Response
+	if api.CompilationCache != nil {
+		stats, err := api.CompilationCache.Stats()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		res.CompilationCache = &stats
+	}
+	stats, err := api.SharedCodeStore.Stats()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	res.SharedCode = stats
+	return ctx.JSON(res)
+}
+
+// PurgeCache removes all cached compilation results.
+func (api *API) PurgeCache(ctx *fiber.Ctx) error {
+	type Response struct {
+		Deleted int `json:"deleted"`
+	}
+
+	if api.CompilationCache == nil {
+		return fiber.NewError(fiber.StatusNotFound, "compilation cache is disabled")
+	}
+	deleted, err := api.CompilationCache.Purge()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(Response{Deleted: deleted})
+}
+
+// DeleteSharedCode removes a shared code snippet.
+func (api *API) DeleteSharedCode(ctx *fiber.Ctx) error {
+	id, err := store.ParseSharedCodeKey(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	var val store.SharedCodeValue
+	if found, err := api.SharedCodeStore.Get(id, &val); !found {
+		return fiber.NewError(fiber.StatusNotFound, "shared code not found")
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	if err := api.SharedCodeStore.Delete(id); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ExportSharedCode returns all shared code snippets as JSON lines.
+func (api *API) ExportSharedCode(ctx *fiber.Ctx) error {
+	if _, err := store.ExportSharedCode(api.SharedCodeStore, ctx); err != nil {
+		ctx.Response().ResetBody()
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	ctx.Set(fiber.HeaderContentType, "application/x-ndjson")
+	return nil
+}
+
+// ImportSharedCode stores shared code snippets in the format of [API.ExportSharedCode].
+// The request body of an admin is streamed, so imports are not limited by the body limit.
+func (api *API) ImportSharedCode(ctx *fiber.Ctx) error {
+	type Response struct {
+		Imported int `json:"imported"`
+	}
+
+	body := ctx.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(ctx.Body())
+	}
+	imported, err := store.ImportSharedCode(api.SharedCodeStore, body, api.validateSharedSession)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.JSON(Response{Imported: imported})
+}
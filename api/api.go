@@ -1,7 +1,16 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/gofiber/fiber/v2"
@@ -19,6 +28,12 @@ type API struct {
 	Compilers        *compilers.Service
 	CompilationCache *store.CompilationCache
 	SharedCodeStore  *store.SharedCode
+
+	compileSlotsOnce sync.Once
+	compileSlots     chan struct{}
+
+	modulesMu sync.Mutex
+	modules   map[modulesKey]resolvedModules
 }
 
 func (api *API) GetCompilers(ctx *fiber.Ctx) error {
@@ -68,23 +83,38 @@ func (api *API) Format(ctx *fiber.Ctx) error {
 }
 
 func (api *API) Compile(ctx *fiber.Ctx) error {
-	type Request struct {
-		Name    string                    `json:"name"`
-		Options compilers.CompilerOptions `json:"options"`
-		Code    string                    `json:"code"`
-	}
-	type Response struct {
-		BuildFailed bool `json:"buildFailed"`
-		parsers.Result
-	}
-
-	var req Request
+	var req CompileRequest
 	if err := ctx.BodyParser(&req); err != nil {
 		return err
 	}
+	res, err := api.compile(ctx.Context(), req)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(res)
+}
+
+type CompileRequest struct {
+	Name    string                    `json:"name"`
+	Options compilers.CompilerOptions `json:"options"`
+	Code    string                    `json:"code"`
+}
+
+type CompileResponse struct {
+	BuildFailed bool `json:"buildFailed"`
+	parsers.Result
+}
+
+// compile compiles and parses the code, using compilation cache if enabled.
+func (api *API) compile(ctx context.Context, req CompileRequest) (CompileResponse, error) {
+	return api.compileWith(ctx, req, api.acquireCompileSlot)
+}
+
+// compileWith is compile that acquires compile slots with acquire, see [API.acquireCompileSlot].
+func (api *API) compileWith(ctx context.Context, req CompileRequest, acquire func(context.Context) (func(), error)) (CompileResponse, error) {
 	compInfo, err := compilers.ParseInfo(req.Name)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return CompileResponse{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 	code := []byte(req.Code)
 
@@ -93,66 +123,316 @@ func (api *API) Compile(ctx *fiber.Ctx) error {
 		compiler = api.Compilers.Get(req.Name)
 	}
 	if compiler == nil {
-		return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("compiler not found: %s", req.Name))
+		return CompileResponse{}, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("compiler not found: %s", req.Name))
 	}
 
-	cacheKey := store.CompilationCacheKey{
-		CompilerName:    compInfo.Name(),
-		CompilerOptions: req.Options,
-		Code:            code,
+	compConfig := compilers.CompilerConfig{
+		Platform:     compInfo.Platform,
+		Architecture: compInfo.Architecture,
+		Options:      req.Options,
 	}
+
+	useCache := api.CompilationCache != nil
+	var cacheKey store.CompilationCacheKey
 	var cacheValue store.CompilationCacheValue
-	if api.CompilationCache != nil {
+	if useCache {
+		cacheKey, err = api.compilationCacheKey(ctx, compiler, compInfo, compConfig, code, acquire)
+		// Results can't be told apart without resolved modules, compile without cache.
+		useCache = err == nil
+	}
+	if useCache {
 		if found, err := api.CompilationCache.Get(cacheKey, &cacheValue); found {
-			return ctx.JSON(Response(cacheValue))
+			return CompileResponse(cacheValue), nil
 		} else if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+			return CompileResponse{}, fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
 	}
 
-	compConfig := compilers.CompilerConfig{
-		Platform:     compInfo.Platform,
-		Architecture: compInfo.Architecture,
-		Options:      req.Options,
+	release, err := acquire(ctx)
+	if err != nil {
+		return CompileResponse{}, fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
 	}
-	compRes, err := compiler.Compile(ctx.Context(), compConfig, code)
+	compRes, err := compiler.Compile(ctx, compConfig, code)
+	release()
 	cacheValue.BuildFailed = err != nil
 
 	parser := parsers.FindMatching(compRes)
 	if parser == nil {
-		return fiber.NewError(fiber.StatusNotFound, "parser not found for go version: ", compRes.CompilerInfo.Version)
+		return CompileResponse{}, fiber.NewError(fiber.StatusNotFound, "parser not found for go version: ", compRes.CompilerInfo.Version)
 	}
 	parseRes := parser.Parse(compRes)
 	cacheValue.Result = parseRes
 
-	if api.CompilationCache != nil {
+	if useCache {
 		if err := api.CompilationCache.Set(cacheKey, cacheValue, api.Config.CompilationCacheTTL); err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+			return CompileResponse{}, fiber.NewError(fiber.StatusInternalServerError, err.Error())
 		}
 	}
 
-	return ctx.JSON(Response{
+	return CompileResponse{
 		BuildFailed: cacheValue.BuildFailed,
 		Result:      parseRes,
+	}, nil
+}
+
+// compilationCacheKey identifies the compilation by the toolchain, options, code and versions of modules it uses.
+func (api *API) compilationCacheKey(ctx context.Context, compiler compilers.Compiler, info compilers.CompilerInfo, config compilers.CompilerConfig, code []byte, acquire func(context.Context) (func(), error)) (store.CompilationCacheKey, error) {
+	toolchain, err := compiler.Toolchain()
+	if err != nil {
+		return store.CompilationCacheKey{}, err
+	}
+	modules, err := api.resolveModules(ctx, compiler, toolchain, config, code, acquire)
+	if err != nil {
+		return store.CompilationCacheKey{}, err
+	}
+	return store.CompilationCacheKey{
+		CompilerName:    info.Name(),
+		Toolchain:       toolchain,
+		CompilerOptions: config.Options,
+		Modules:         modules,
+		Code:            code,
+	}, nil
+}
+
+// Resolved versions of modules are reused for this long, after which updated
+// dependencies are picked up.
+const modulesTTL = 10 * time.Minute
+
+// modulesKey identifies go.mod and go.sum generated for code by the toolchain, target
+// platform and hash of the imported packages outside of the standard library.
+type modulesKey struct {
+	toolchain    compilers.Toolchain
+	platform     string
+	architecture string
+	imports      [sha256.Size]byte
+}
+
+type resolvedModules struct {
+	modules []string
+	expires time.Time
+}
+
+// resolveModules returns versions of modules used by the code, resolving them with the compiler
+// only if they were not resolved for the same imports and toolchain recently.
+func (api *API) resolveModules(ctx context.Context, compiler compilers.Compiler, toolchain compilers.Toolchain, config compilers.CompilerConfig, code []byte, acquire func(context.Context) (func(), error)) ([]string, error) {
+	imports := compilers.ModuleImports(code)
+	if len(imports) == 0 {
+		return nil, nil
+	}
+	key := modulesKey{
+		toolchain:    toolchain,
+		platform:     config.Platform,
+		architecture: config.Architecture,
+		imports:      sha256.Sum256([]byte(strings.Join(imports, "\n"))),
+	}
+	now := time.Now()
+	api.modulesMu.Lock()
+	resolved, ok := api.modules[key]
+	api.modulesMu.Unlock()
+	if ok && now.Before(resolved.expires) {
+		return resolved.modules, nil
+	}
+
+	release, err := acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := compiler.Modules(ctx, config, code)
+	release()
+	if err != nil {
+		return nil, err
+	}
+
+	api.modulesMu.Lock()
+	defer api.modulesMu.Unlock()
+	if api.modules == nil {
+		api.modules = map[modulesKey]resolvedModules{}
+	}
+	maps.DeleteFunc(api.modules, func(_ modulesKey, r resolvedModules) bool {
+		return now.After(r.expires)
+	})
+	api.modules[key] = resolvedModules{modules: modules, expires: now.Add(modulesTTL)}
+	return modules, nil
+}
+
+// acquireCompileSlot waits until the number of running compilations is below the limit.
+func (api *API) acquireCompileSlot(ctx context.Context) (func(), error) {
+	slots := api.getCompileSlots()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+var errCompilersBusy = errors.New("all compilers are busy")
+
+// tryAcquireCompileSlot is acquireCompileSlot that returns errCompilersBusy instead of
+// waiting, for compilations that must not delay others.
+func (api *API) tryAcquireCompileSlot(context.Context) (func(), error) {
+	slots := api.getCompileSlots()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+		return nil, errCompilersBusy
+	}
+}
+
+func (api *API) getCompileSlots() chan struct{} {
+	api.compileSlotsOnce.Do(func() {
+		limit := api.Config.Compilers.MaxConcurrent
+		if limit <= 0 {
+			limit = runtime.NumCPU()
+		}
+		api.compileSlots = make(chan struct{}, limit)
+	})
+	return api.compileSlots
+}
+
+// Diff compiles two versions of code, possibly with different compilers and options,
+// and compares their assembly and diagnostics.
+func (api *API) Diff(ctx *fiber.Ctx) error {
+	type Request struct {
+		Before CompileRequest `json:"before"`
+		After  CompileRequest `json:"after"`
+	}
+	type Response struct {
+		Before CompileResponse    `json:"before"`
+		After  CompileResponse    `json:"after"`
+		Diff   parsers.ResultDiff `json:"diff"`
+	}
+
+	var req Request
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	before, err := api.compile(ctx.Context(), req.Before)
+	if err != nil {
+		return err
+	}
+	after, err := api.compile(ctx.Context(), req.After)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(Response{
+		Before: before,
+		After:  after,
+		Diff:   parsers.Diff(before.Result, after.Result, []byte(req.Before.Code), []byte(req.After.Code)),
 	})
 }
 
+// ShareCode stores the shared session from the request body, see [store.SharedSession].
+// Options are passed in query parameters:
+//   - ttl: duration until the shared code expires, within configured bounds;
+//   - permanent: whether the shared code never expires;
+//   - parent: ID of the shared code this one is forked from.
+//
+// Sharing the same code with the same options again returns the same ID and extends its
+// expiry. The response contains a token to delete the shared code with [API.UnshareCode].
 func (api *API) ShareCode(ctx *fiber.Ctx) error {
 	type Response struct {
-		ID string `json:"id"`
+		ID          string     `json:"id"`
+		DeleteToken string     `json:"deleteToken,omitempty"`
+		Expires     *time.Time `json:"expires,omitempty"`
+	}
+	ttl, err := api.sharedCodeTTL(ctx.Query("ttl"), ctx.QueryBool("permanent"))
+	if err != nil {
+		return err
+	}
+	session, err := store.DecodeSharedSession(ctx.Body())
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err := api.validateSharedSession(&session); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	code, err := json.Marshal(session)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
-	id := store.NewSharedCodeKey()
 	val := store.SharedCodeValue{
-		Code: ctx.Body(),
+		Code: code,
+	}
+	if parent := ctx.Query("parent"); parent != "" {
+		parentID, err := store.ParseSharedCodeKey(parent)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid parent: "+err.Error())
+		}
+		var parentVal store.SharedCodeValue
+		if found, err := api.SharedCodeStore.Get(parentID, &parentVal); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		} else if !found {
+			return fiber.NewError(fiber.StatusBadRequest, "parent shared code not found")
+		}
+		val.Parent = parentID.String()
 	}
-	if err := api.SharedCodeStore.Set(id, val, api.Config.SharedCodeTTL); err != nil {
+	token, hash := store.NewDeleteToken()
+	val.DeleteTokenHash = hash
+
+	id, _, err := store.Share(api.SharedCodeStore, &val, ttl)
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
-	return ctx.JSON(Response{
-		ID: id.String(),
-	})
+	res := Response{
+		ID:          id.String(),
+		DeleteToken: token,
+	}
+	if !val.Expires.IsZero() {
+		res.Expires = &val.Expires
+	}
+	return ctx.JSON(res)
+}
+
+// sharedCodeTTL returns the TTL of shared code with the requested options, zero if it never expires.
+func (api *API) sharedCodeTTL(ttlOption string, permanent bool) (time.Duration, error) {
+	cfg := &api.Config.SharedCode
+	if permanent {
+		if !cfg.AllowPermanent {
+			return 0, fiber.NewError(fiber.StatusForbidden, "permanent shared code is disabled")
+		}
+		return 0, nil
+	}
+	if ttlOption == "" {
+		return api.Config.SharedCodeTTL, nil
+	}
+	ttl, err := time.ParseDuration(ttlOption)
+	if err != nil {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid ttl: "+err.Error())
+	}
+	// Zero TTL would make shared code permanent.
+	if minTTL := max(cfg.MinTTL, time.Second); ttl < minTTL {
+		return 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("ttl must be at least %s", minTTL))
+	}
+	if cfg.MaxTTL > 0 && ttl > cfg.MaxTTL {
+		return 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("ttl must be at most %s", cfg.MaxTTL))
+	}
+	return ttl, nil
+}
+
+// validateSharedSession checks the session against the configuration and available compilers.
+func (api *API) validateSharedSession(s *store.SharedSession) error {
+	return s.Validate(api.Config.SharedCode.MaxTabs, api.isKnownCompiler, api.defaultArchitecture())
+}
+
+// isKnownCompiler reports whether the compiler is available.
+func (api *API) isKnownCompiler(name string) bool {
+	return api.Compilers.Get(name) != nil
+}
+
+// defaultArchitecture returns the architecture of the default compiler, or empty if it is unknown.
+func (api *API) defaultArchitecture() string {
+	if c := api.Compilers.Default(); c != nil {
+		if info, err := c.Info(); err == nil {
+			return info.Architecture
+		}
+	}
+	return ""
 }
 
+// GetSharedCode returns the shared session, migrated to the current version.
 func (api *API) GetSharedCode(ctx *fiber.Ctx) error {
 	id, err := store.ParseSharedCodeKey(ctx.Params("id"))
 	if err != nil {
@@ -164,9 +444,52 @@ func (api *API) GetSharedCode(ctx *fiber.Ctx) error {
 	} else if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
-	ctx.Response().Header.Set("Content-Type", "application/octet-stream")
-	ctx.Response().SetBody(val.Code)
-	return nil
+	session, err := store.ParseSharedSession(val.Code)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(session)
+}
+
+// GetSharedCodeInfo returns information about shared code other than the code itself.
+func (api *API) GetSharedCodeInfo(ctx *fiber.Ctx) error {
+	type Response struct {
+		ID     string `json:"id"`
+		Parent string `json:"parent,omitempty"`
+	}
+	id, err := store.ParseSharedCodeKey(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	var val store.SharedCodeValue
+	if found, err := api.SharedCodeStore.Get(id, &val); !found {
+		return fiber.NewError(fiber.StatusNotFound, "shared code not found")
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(Response{
+		ID:     id.String(),
+		Parent: val.Parent,
+	})
+}
+
+// UnshareCode deletes shared code by the delete token returned by [API.ShareCode],
+// passed in the Authorization header as a bearer token. Shared code is kept until
+// everyone who shared it deletes it.
+func (api *API) UnshareCode(ctx *fiber.Ctx) error {
+	id, err := store.ParseSharedCodeKey(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	token, _ := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+	if found, err := store.Unshare(api.SharedCodeStore, id, token); errors.Is(err, store.ErrInvalidDeleteToken) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	} else if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	} else if !found {
+		return fiber.NewError(fiber.StatusNotFound, "shared code not found")
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
 }
 
 func (api *API) gofumptVersionForCompiler(name string) string {
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/w1ck3dg0ph3r/goce/compilers"
+)
+
+// maxBatchCells limits the number of compilations in a batch.
+const maxBatchCells = 32
+
+// Batch compiles the code with every combination of compilers and options.
+func (api *API) Batch(ctx *fiber.Ctx) error {
+	type Request struct {
+		Names   []string                    `json:"names"`
+		Options []compilers.CompilerOptions `json:"options"`
+		Code    string                      `json:"code"`
+	}
+	type Cell struct {
+		Name    string                    `json:"name"`
+		Options compilers.CompilerOptions `json:"options"`
+		Error   string                    `json:"error,omitempty"`
+		*CompileResponse
+	}
+	type Response struct {
+		Cells []Cell `json:"cells"`
+	}
+
+	var req Request
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	if len(req.Names) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "no compilers specified")
+	}
+	if len(req.Options) == 0 {
+		req.Options = []compilers.CompilerOptions{{}}
+	}
+	if cells := len(req.Names) * len(req.Options); cells > maxBatchCells {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("too many compilations: %d, max %d", cells, maxBatchCells))
+	}
+
+	reqs := make([]CompileRequest, 0, len(req.Names)*len(req.Options))
+	for _, name := range req.Names {
+		for _, opts := range req.Options {
+			reqs = append(reqs, CompileRequest{Name: name, Options: opts, Code: req.Code})
+		}
+	}
+	results := api.compileAll(ctx.Context(), reqs)
+
+	res := Response{Cells: make([]Cell, 0, len(results))}
+	for i, r := range results {
+		cell := Cell{Name: reqs[i].Name, Options: reqs[i].Options}
+		if r.Err != nil {
+			cell.Error = r.Err.Error()
+		} else {
+			cell.CompileResponse = &r.CompileResponse
+		}
+		res.Cells = append(res.Cells, cell)
+	}
+	return ctx.JSON(res)
+}
+
+type compileResult struct {
+	CompileResponse
+	Err error
+}
+
+// compileAll compiles requests in parallel, within the limit of concurrent compilations.
+func (api *API) compileAll(ctx context.Context, reqs []CompileRequest) []compileResult {
+	results := make([]compileResult, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].CompileResponse, results[i].Err = api.compile(ctx, req)
+		}()
+	}
+	wg.Wait()
+	return results
+}
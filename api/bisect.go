@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/w1ck3dg0ph3r/goce/compilers"
+	"github.com/w1ck3dg0ph3r/goce/parsers"
+)
+
+// Bisect compiles the code with every available compiler for the platform and architecture
+// and groups consecutive versions producing the same assembly and diagnostics.
+func (api *API) Bisect(ctx *fiber.Ctx) error {
+	type Request struct {
+		Platform     string                    `json:"platform"`
+		Architecture string                    `json:"architecture"`
+		Options      compilers.CompilerOptions `json:"options"`
+		Code         string                    `json:"code"`
+	}
+	// Group is a range of versions with the same output.
+	type Group struct {
+		Versions []string         `json:"versions"`
+		Error    string           `json:"error,omitempty"`
+		Result   *CompileResponse `json:"result,omitempty"` // Result of the first version.
+	}
+	// Boundary is a change of output between two consecutive versions.
+	type Boundary struct {
+		Before      string `json:"before"`
+		After       string `json:"after"`
+		Assembly    bool   `json:"assembly"`
+		Diagnostics bool   `json:"diagnostics"`
+		BuildFailed bool   `json:"buildFailed"`
+	}
+	type Response struct {
+		Platform     string     `json:"platform"`
+		Architecture string     `json:"architecture"`
+		Groups       []Group    `json:"groups"`
+		Boundaries   []Boundary `json:"boundaries"`
+	}
+
+	var req Request
+	if err := ctx.BodyParser(&req); err != nil {
+		return err
+	}
+	infos := api.Compilers.List()
+	if len(infos) == 0 {
+		return fiber.NewError(fiber.StatusNotFound, compilers.ErrNoCompilers.Error())
+	}
+	// Platform and architecture default to those of the default compiler, as in compilation.
+	defaultInfo := infos[0]
+	if c := api.Compilers.Default(); c != nil {
+		if info, err := c.Info(); err == nil {
+			defaultInfo = info
+		}
+	}
+	if req.Platform == "" {
+		req.Platform = defaultInfo.Platform
+	}
+	if req.Architecture == "" {
+		req.Architecture = defaultInfo.Architecture
+	}
+	infos = compilersFor(infos, req.Platform, req.Architecture)
+	if len(infos) == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "no compilers found for "+req.Platform+"/"+req.Architecture)
+	}
+
+	outputs := api.compileVersions(ctx.Context(), infos, req.Options, req.Code)
+
+	res := Response{
+		Platform:     req.Platform,
+		Architecture: req.Architecture,
+		Groups:       []Group{},
+		Boundaries:   []Boundary{},
+	}
+	for i, out := range outputs {
+		if i > 0 {
+			prev := outputs[i-1]
+			b := Boundary{
+				Before:      prev.Version,
+				After:       out.Version,
+				Assembly:    prev.Assembly != out.Assembly,
+				Diagnostics: prev.Diagnostics != out.Diagnostics,
+				BuildFailed: prev.BuildFailed != out.BuildFailed || prev.Error != out.Error,
+			}
+			if !b.Assembly && !b.Diagnostics && !b.BuildFailed {
+				g := &res.Groups[len(res.Groups)-1]
+				g.Versions = append(g.Versions, out.Version)
+				continue
+			}
+			res.Boundaries = append(res.Boundaries, b)
+		}
+		g := Group{Versions: []string{out.Version}, Error: out.Error}
+		if out.Error == "" {
+			g.Result = &out.Response
+		}
+		res.Groups = append(res.Groups, g)
+	}
+
+	return ctx.JSON(res)
+}
+
+// compilersFor returns compilers for the platform and architecture ordered by ascending version.
+func compilersFor(infos []compilers.CompilerInfo, platform, architecture string) []compilers.CompilerInfo {
+	type versioned struct {
+		info    compilers.CompilerInfo
+		version *semver.Version
+	}
+	var list []versioned
+	for _, info := range infos {
+		if info.Platform != platform || info.Architecture != architecture {
+			continue
+		}
+		ver, err := semver.NewVersion(info.Version)
+		if err != nil {
+			continue
+		}
+		list = append(list, versioned{info: info, version: ver})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].version.LessThan(list[j].version) })
+	res := make([]compilers.CompilerInfo, 0, len(list))
+	for _, v := range list {
+		res = append(res, v.info)
+	}
+	return res
+}
+
+// compilerOutput is a compilation result along with its fingerprints.
+type compilerOutput struct {
+	Version     string
+	Response    CompileResponse
+	Error       string
+	BuildFailed bool
+	Assembly    string
+	Diagnostics string
+}
+
+// compileVersions compiles the code with each of the compilers and fingerprints the results.
+func (api *API) compileVersions(ctx context.Context, infos []compilers.CompilerInfo, options compilers.CompilerOptions, code string) []compilerOutput {
+	reqs := make([]CompileRequest, 0, len(infos))
+	for _, info := range infos {
+		reqs = append(reqs, CompileRequest{Name: info.Name(), Options: options, Code: code})
+	}
+	results := api.compileAll(ctx, reqs)
+	outputs := make([]compilerOutput, len(infos))
+	for i, res := range results {
+		out := &outputs[i]
+		out.Version = infos[i].Version
+		if res.Err != nil {
+			out.Error = res.Err.Error()
+			continue
+		}
+		out.Response = res.CompileResponse
+		out.BuildFailed = res.BuildFailed
+		out.Assembly, out.Diagnostics = parsers.Fingerprint(res.Result, []byte(code))
+	}
+	return outputs
+}
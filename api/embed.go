@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/w1ck3dg0ph3r/goce/store"
+)
+
+// Embed renders a code tab of shared code with its assembly as a lightweight HTML page
+// to be embedded into other sites. The tab is chosen by the tab query parameter, the active
+// or the first code tab is shown by default.
+//
+// Embedded pages are requested by visitors of other sites, so they are only compiled when
+// a compiler is idle, and link to goce for the assembly otherwise.
+func (api *API) Embed(ctx *fiber.Ctx) error {
+	id, session, err := api.getSharedSession(ctx.Params("id"))
+	if err != nil {
+		return err
+	}
+	tabIndex := ctx.QueryInt("tab", session.DefaultCodeTab())
+	if tabIndex < 0 || tabIndex >= len(session.Tabs) || session.Tabs[tabIndex].Type != store.SharedTabCode {
+		return fiber.NewError(fiber.StatusNotFound, "code tab not found")
+	}
+	tab := session.Tabs[tabIndex]
+
+	compilerName := ""
+	if c := tab.Settings.Compiler; c != nil && api.isKnownCompiler(c.Name) {
+		compilerName = c.Name
+	} else if c := api.Compilers.Default(); c != nil {
+		info, err := c.Info()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+		compilerName = info.Name()
+	}
+	res, err := api.compileWith(ctx.Context(), CompileRequest{
+		Name:    compilerName,
+		Options: tab.Settings.CompilerOptions,
+		Code:    tab.Code,
+	}, api.tryAcquireCompileSlot)
+	var fe *fiber.Error
+	busy := errors.As(err, &fe) && fe.Code == fiber.StatusServiceUnavailable
+	if err != nil && !busy {
+		return err
+	}
+
+	data := embedData{
+		Title:       tab.Name,
+		Compiler:    compilerName,
+		URL:         ctx.BaseURL() + "/" + id.String(),
+		OEmbedURL:   ctx.BaseURL() + "/api/oembed?url=" + url.QueryEscape(ctx.BaseURL()+ctx.OriginalURL()),
+		Source:      strings.Split(strings.TrimSuffix(tab.Code, "\n"), "\n"),
+		BuildFailed: res.BuildFailed,
+		Output:      res.Assembly,
+	}
+	if res.BuildFailed {
+		data.Output = res.BuildOutput
+	}
+	if busy {
+		data.Busy = true
+		data.Output = "Compilers are busy, open in goce to see the assembly."
+	}
+	var buf bytes.Buffer
+	if err := embedTemplate.Execute(&buf, data); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	ctx.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	if busy {
+		ctx.Set(fiber.HeaderCacheControl, "no-store")
+	} else {
+		ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	}
+	return ctx.Send(buf.Bytes())
+}
+
+// OEmbed describes an embedding of shared code by its URL for oEmbed consumers,
+// see https://oembed.com. Only the JSON format is supported.
+func (api *API) OEmbed(ctx *fiber.Ctx) error {
+	type Response struct {
+		Version      string `json:"version"`
+		Type         string `json:"type"`
+		Title        string `json:"title"`
+		ProviderName string `json:"provider_name"`
+		ProviderURL  string `json:"provider_url"`
+		HTML         string `json:"html"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+	}
+	const defaultWidth, defaultHeight = 800, 400
+
+	if format := ctx.Query("format", "json"); format != "json" {
+		return fiber.NewError(fiber.StatusNotImplemented, "unsupported format: "+format)
+	}
+	u, err := url.Parse(ctx.Query("url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host != ctx.Hostname() {
+		return fiber.NewError(fiber.StatusNotFound, "not a shared code url")
+	}
+	// Both links to the UI and to the embedded page are accepted.
+	id, session, err := api.getSharedSession(strings.TrimPrefix(strings.TrimPrefix(u.Path, "/embed"), "/"))
+	if err != nil {
+		return err
+	}
+	tabIndex := session.DefaultCodeTab()
+	embedURL := ctx.BaseURL() + "/embed/" + id.String()
+	if tab := u.Query().Get("tab"); tab != "" {
+		if tabIndex, err = strconv.Atoi(tab); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid tab: "+tab)
+		}
+		embedURL += "?tab=" + url.QueryEscape(tab)
+	}
+	if tabIndex < 0 || tabIndex >= len(session.Tabs) || session.Tabs[tabIndex].Type != store.SharedTabCode {
+		return fiber.NewError(fiber.StatusNotFound, "code tab not found")
+	}
+
+	width, height := defaultWidth, defaultHeight
+	if maxWidth := ctx.QueryInt("maxwidth"); maxWidth > 0 {
+		width = min(width, maxWidth)
+	}
+	if maxHeight := ctx.QueryInt("maxheight"); maxHeight > 0 {
+		height = min(height, maxHeight)
+	}
+	html := fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" loading="lazy"></iframe>`,
+		template.HTMLEscapeString(embedURL), width, height)
+	return ctx.JSON(Response{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        session.Tabs[tabIndex].Name,
+		ProviderName: "goce",
+		ProviderURL:  ctx.BaseURL(),
+		HTML:         html,
+		Width:        width,
+		Height:       height,
+	})
+}
+
+// getSharedSession returns the shared session by its ID.
+func (api *API) getSharedSession(idString string) (store.SharedCodeKey, store.SharedSession, error) {
+	id, err := store.ParseSharedCodeKey(idString)
+	if err != nil {
+		return id, store.SharedSession{}, fiber.NewError(fiber.StatusNotFound, "shared code not found")
+	}
+	var val store.SharedCodeValue
+	if found, err := api.SharedCodeStore.Get(id, &val); err != nil {
+		return id, store.SharedSession{}, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	} else if !found {
+		return id, store.SharedSession{}, fiber.NewError(fiber.StatusNotFound, "shared code not found")
+	}
+	session, err := store.ParseSharedSession(val.Code)
+	if err != nil {
+		return id, store.SharedSession{}, fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return id, session, nil
+}
+
+type embedData struct {
+	Title       string
+	Compiler    string
+	URL         string
+	OEmbedURL   string
+	Source      []string
+	BuildFailed bool
+	// Whether the code is not compiled because compilers are busy.
+	Busy   bool
+	Output string
+}
+
+var embedTemplate = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - goce</title>
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+<style>
+  html, body { margin: 0; height: 100%; font: 13px/1.4 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; color: #d4d4d4; background: #1e1e1e; }
+  body { display: flex; flex-direction: column; }
+  header { display: flex; gap: 1em; align-items: baseline; padding: 4px 8px; background: #2d2d2d; }
+  header a { margin-left: auto; color: #4fc1ff; }
+  main { display: flex; flex: 1; min-height: 0; }
+  pre { flex: 1; margin: 0; padding: 4px 8px; overflow: auto; }
+  pre + pre { border-left: 1px solid #3c3c3c; }
+  ol { margin: 0; padding-left: 4ch; }
+  li::marker { color: #858585; }
+  .failed { color: #f48771; }
+  .busy { color: #858585; }
+</style>
+</head>
+<body>
+<header>
+  <strong>{{.Title}}</strong>
+  <span>{{.Compiler}}</span>
+  <a href="{{.URL}}" target="_blank" rel="noopener">Open in goce</a>
+</header>
+<main>
+  <pre><ol>{{range .Source}}<li>{{.}}</li>{{end}}</ol></pre>
+  <pre{{if .BuildFailed}} class="failed"{{else if .Busy}} class="busy"{{end}}>{{.Output}}</pre>
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/w1ck3dg0ph3r/goce/compilers"
+)
+
+const usage = `gencorpus Captures compiler output for the parser regression corpus
+
+Every directory in the corpus containing main.go is compiled with every
+matching compiler. Build output and JSON diagnostics are written into
+<snippet>/<compiler>/ next to the source.
+
+Golden results are regenerated from the captured output by running:
+  go test ./parsers -run TestCorpus -update
+
+Usage:
+gencorpus [flags]
+
+Flags:
+`
+
+func main() {
+	corpusDir := flag.String("corpus", "parsers/testdata/corpus", "Corpus directory")
+	searchGoPath := flag.Bool("path", true, "Search $PATH for go compilers")
+	searchSDKPath := flag.Bool("sdk", true, "Search $HOME/sdk/go* for go compilers")
+	localCompilers := flag.String("go", "", "Comma separated paths of additional go executables")
+	archs := flag.String("arch", "amd64,arm64", "Comma separated architectures to capture")
+	run := flag.String("run", "", "Capture only snippets matching the regular expression")
+	flag.Usage = func() {
+		fmt.Print(usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Capture output of the exact toolchain, even when run inside a module
+	// that requires a newer go version.
+	if err := os.Setenv("GOTOOLCHAIN", "local"); err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	svc, err := compilers.New(&compilers.Config{
+		SearchGoPath:            *searchGoPath,
+		SearchSDKPath:           *searchSDKPath,
+		LocalCompilers:          splitList(*localCompilers),
+		AdditionalArchitectures: true,
+		EnableModules:           true,
+	})
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	filter, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	snippets, err := listSnippets(*corpusDir, filter)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	architectures := splitList(*archs)
+	failed := false
+	for _, info := range svc.List() {
+		if !slices.Contains(architectures, info.Architecture) {
+			continue
+		}
+		for _, snippet := range snippets {
+			fmt.Printf("capturing %s with %s\n", filepath.Base(snippet), info.Name())
+			if err := capture(svc, info, snippet); err != nil {
+				fmt.Printf("error: %v\n", err)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	fmt.Printf("done\n")
+}
+
+func listSnippets(corpusDir string, filter *regexp.Regexp) ([]string, error) {
+	entries, err := os.ReadDir(corpusDir)
+	if err != nil {
+		return nil, fmt.Errorf("read corpus dir: %w", err)
+	}
+	var snippets []string
+	for _, e := range entries {
+		if !e.IsDir() || !filter.MatchString(e.Name()) {
+			continue
+		}
+		dir := filepath.Join(corpusDir, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, "main.go")); err != nil {
+			continue
+		}
+		snippets = append(snippets, dir)
+	}
+	return snippets, nil
+}
+
+func capture(svc *compilers.Service, info compilers.CompilerInfo, snippet string) error {
+	code, err := os.ReadFile(filepath.Join(snippet, "main.go"))
+	if err != nil {
+		return fmt.Errorf("read source: %w", err)
+	}
+
+	compiler := svc.Get(info.Name())
+	if compiler == nil {
+		return fmt.Errorf("compiler not found: %s", info.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	res, err := compiler.Compile(ctx, compilers.CompilerConfig{
+		Platform:     info.Platform,
+		Architecture: info.Architecture,
+	}, code)
+	if err != nil {
+		return fmt.Errorf("compile: %w\n%s", err, res.BuildOutput)
+	}
+
+	dir := filepath.Join(snippet, caseDirName(info))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("create case dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "buildoutput"), res.BuildOutput, 0o600); err != nil {
+		return fmt.Errorf("write build output: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "build.json"), res.BuildJSON, 0o600); err != nil {
+		return fmt.Errorf("write build json: %w", err)
+	}
+	return nil
+}
+
+// caseDirName must be kept in sync with the corpus test in parsers package.
+func caseDirName(info compilers.CompilerInfo) string {
+	return fmt.Sprintf("go%s_%s_%s", info.Version, info.Platform, info.Architecture)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -31,6 +32,8 @@ var (
 	ErrInvalidName = errors.New("invalid compiler name")
 	ErrInvalidPath = errors.New("invalid compiler path")
 	ErrBuildFailed = errors.New("build failed")
+
+	ErrInvalidArchitectureLevel = errors.New("invalid architecture level")
 )
 
 // New creates and initializes [Service].
@@ -61,6 +64,8 @@ type availableCompilers struct {
 
 type Compiler interface {
 	Info() (CompilerInfo, error)
+	Toolchain() (Toolchain, error)
+	Modules(ctx context.Context, cfg CompilerConfig, code []byte) ([]string, error)
 	Compile(ctx context.Context, cfg CompilerConfig, code []byte) (Result, error)
 }
 
@@ -82,6 +87,30 @@ type CompilerOptions struct {
 	ArchitectureLevel    string `json:"architectureLevel"`
 }
 
+// Validate checks that the options are supported by the target architecture.
+func (o CompilerOptions) Validate(architecture string) error {
+	if o.ArchitectureLevel == "" || slices.Contains(ArchitectureLevels(architecture), o.ArchitectureLevel) {
+		return nil
+	}
+	return fmt.Errorf("%w for %s: %q", ErrInvalidArchitectureLevel, architecture, o.ArchitectureLevel)
+}
+
+// ArchitectureLevels returns supported values of the architecture level option, which sets
+// GOAMD64, GOPPC64, GO386 or GOARM depending on the architecture.
+func ArchitectureLevels(architecture string) []string {
+	switch architecture {
+	case "amd64":
+		return []string{"v1", "v2", "v3", "v4"}
+	case "ppc64", "ppc64le":
+		return []string{"power8", "power9"}
+	case "386":
+		return []string{"softfloat", "sse2"}
+	case "arm":
+		return []string{"5", "6", "7"}
+	}
+	return nil
+}
+
 type Result struct {
 	CompilerInfo   CompilerInfo `json:"compilerInfo"`
 	SourceFilename string       `json:"sourceFilename"`
@@ -0,0 +1,54 @@
+package compilers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestArchitectureLevels(t *testing.T) {
+	tests := map[string][]string{
+		"amd64":   {"v1", "v2", "v3", "v4"},
+		"ppc64":   {"power8", "power9"},
+		"ppc64le": {"power8", "power9"},
+		"386":     {"softfloat", "sse2"},
+		"arm":     {"5", "6", "7"},
+		"arm64":   nil,
+		"":        nil,
+	}
+	for architecture, want := range tests {
+		if got := ArchitectureLevels(architecture); !reflect.DeepEqual(got, want) {
+			t.Errorf("ArchitectureLevels(%q) = %v, want %v", architecture, got, want)
+		}
+	}
+}
+
+func TestCompilerOptionsValidate(t *testing.T) {
+	tests := []struct {
+		architecture string
+		level        string
+		valid        bool
+	}{
+		{"amd64", "", true},
+		{"amd64", "v3", true},
+		{"amd64", "v5", false},
+		{"ppc64", "power9", true},
+		{"ppc64le", "power8", true},
+		{"ppc64le", "v1", false},
+		{"386", "sse2", true},
+		{"arm", "7", true},
+		{"arm", "8", false},
+		{"arm64", "", true},
+		{"arm64", "v8.0", false},
+		{"", "v1", false},
+	}
+	for _, tt := range tests {
+		err := CompilerOptions{ArchitectureLevel: tt.level}.Validate(tt.architecture)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("Validate(%q) of level %q = %v, want valid %v", tt.architecture, tt.level, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidArchitectureLevel) {
+			t.Errorf("Validate(%q) of level %q = %v, want %v", tt.architecture, tt.level, err, ErrInvalidArchitectureLevel)
+		}
+	}
+}
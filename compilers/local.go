@@ -143,6 +143,7 @@ func (r *localRun) Build(ctx context.Context, res *Result) error {
 	}
 	gcflags = append(gcflags, "-m=2")
 	gcflags = append(gcflags, "-S")
+	gcflags = append(gcflags, "-d=ssa/prove/debug=1,ssa/check_bce/debug=1")
 	gcflags = append(gcflags, "-json=0,"+filepath.Join(r.buildDir, ".build.json"))
 	args = append(args, "-gcflags", strings.Join(gcflags, " "))
 	args = append(args, r.mainFilename)
@@ -173,7 +174,7 @@ func (r *localRun) BuildEnv() []string {
 		switch r.Config.Architecture {
 		case "amd64":
 			e = append(e, fmt.Sprintf("GOAMD64=%s", r.Config.Options.ArchitectureLevel))
-		case "ppc64":
+		case "ppc64", "ppc64le":
 			e = append(e, fmt.Sprintf("GOPPC64=%s", r.Config.Options.ArchitectureLevel))
 		case "386":
 			e = append(e, fmt.Sprintf("GO386=%s", r.Config.Options.ArchitectureLevel))
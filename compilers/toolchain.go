@@ -0,0 +1,181 @@
+package compilers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Toolchain identifies the build of a compiler and the environment it runs in,
+// so that different builds of the same go version can be told apart.
+type Toolchain struct {
+	// Hash of the go command and compiler executables.
+	ID string `json:"id"`
+	// GOFLAGS of the build environment.
+	GoFlags string `json:"goFlags,omitempty"`
+}
+
+// Toolchains are hashed once per executable, unless it changes.
+var (
+	toolchainsMu sync.Mutex
+	toolchains   = map[string]toolchainEntry{}
+)
+
+type toolchainEntry struct {
+	toolchain   Toolchain
+	compilePath string
+	goStamp     fileStamp
+	compStamp   fileStamp
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func stampFile(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: fi.Size(), modTime: fi.ModTime()}, nil
+}
+
+func (c *localCompiler) Toolchain() (Toolchain, error) {
+	goStamp, err := stampFile(c.GoPath)
+	if err != nil {
+		return Toolchain{}, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+
+	toolchainsMu.Lock()
+	entry, ok := toolchains[c.GoPath]
+	toolchainsMu.Unlock()
+	if ok && entry.goStamp == goStamp {
+		if compStamp, err := stampFile(entry.compilePath); err == nil && compStamp == entry.compStamp {
+			return entry.toolchain, nil
+		}
+	}
+
+	out, err := exec.Command(c.GoPath, "env", "GOTOOLDIR", "GOFLAGS").Output()
+	if err != nil {
+		return Toolchain{}, fmt.Errorf("go env: %w", err)
+	}
+	env := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(env) != 2 {
+		return Toolchain{}, fmt.Errorf("go env: unexpected output %q", out)
+	}
+	entry = toolchainEntry{
+		compilePath: filepath.Join(env[0], "compile"),
+		goStamp:     goStamp,
+	}
+	entry.toolchain.GoFlags = env[1]
+	if entry.compStamp, err = stampFile(entry.compilePath); err != nil {
+		return Toolchain{}, fmt.Errorf("compiler executable: %w", err)
+	}
+
+	h := sha256.New()
+	for _, path := range []string{c.GoPath, entry.compilePath} {
+		if err := hashFile(h, path); err != nil {
+			return Toolchain{}, fmt.Errorf("hash toolchain: %w", err)
+		}
+	}
+	entry.toolchain.ID = hex.EncodeToString(h.Sum(nil))
+
+	toolchainsMu.Lock()
+	toolchains[c.GoPath] = entry
+	toolchainsMu.Unlock()
+	return entry.toolchain, nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Modules resolves versions of the modules required by the code, if it imports
+// packages outside of the standard library. Versions are returned as "path version".
+func (c *localCompiler) Modules(ctx context.Context, config CompilerConfig, code []byte) ([]string, error) {
+	if !c.EnableModules || !ImportsModules(code) {
+		return nil, nil
+	}
+	info, err := c.Info()
+	if err != nil {
+		return nil, fmt.Errorf("get compiler info: %w", err)
+	}
+	run := &localRun{
+		GoPath: c.GoPath,
+		Code:   code,
+		Info:   info,
+		Config: config,
+	}
+	if err := run.Prepare(); err != nil {
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	defer run.Close()
+	if err := run.InitModules(ctx); err != nil {
+		return nil, fmt.Errorf("init modules: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, run.GoPath, "list", "-m", "all")
+	cmd.Dir = run.buildDir
+	cmd.Env = run.BuildEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+	var modules []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		// The first line is the main module.
+		if line := sc.Text(); strings.Contains(line, " ") {
+			modules = append(modules, line)
+		}
+	}
+	return modules, nil
+}
+
+// ImportsModules reports whether the code imports packages outside of the standard library.
+func ImportsModules(code []byte) bool {
+	return len(ModuleImports(code)) > 0
+}
+
+// ModuleImports returns sorted paths of packages outside of the standard library imported
+// by the code. They determine go.mod and go.sum generated for the code.
+func ModuleImports(code []byte) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", code, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, imp := range file.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		first, _, _ := strings.Cut(path, "/")
+		if strings.Contains(first, ".") {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
@@ -15,9 +15,12 @@ type Config struct {
 
 	// TTL of the compilation result cache.
 	CompilationCacheTTL time.Duration
-	// TTL of the shared code.
+	// Default TTL of the shared code.
 	SharedCodeTTL time.Duration
 
+	// Token authorizing requests to the admin API, disabled if empty.
+	AdminToken string
+
 	Compilers struct {
 		// Search $PATH for go compilers.
 		SearchGoPath bool
@@ -32,34 +35,96 @@ type Config struct {
 
 		// Enable modules support.
 		EnableModules bool
+
+		// Maximum number of concurrent compilations, number of CPUs if zero.
+		MaxConcurrent int
 	}
 
 	Cache struct {
 		Enabled bool
+		// Storage backend of the compilation cache.
+		Backend Backend
+		// Maximum number of entries, unbounded if zero.
+		MaxEntries int
+		// Maximum total size of entries in bytes, unbounded if zero.
+		MaxSize int64
+		// Eviction policy used when the cache exceeds its limits: "lru" or "lfu".
+		Eviction string
+		// Compression of large entries: "none", "zstd" or "brotli".
+		Compression string
+	}
+
+	SharedCode struct {
+		// Storage backend of the shared code.
+		Backend Backend
+		// Maximum number of entries, least recently used are removed first, unbounded if zero.
+		MaxEntries int
+		// Bounds of the TTL requested when sharing code, the maximum is unbounded if zero.
+		MinTTL time.Duration
+		MaxTTL time.Duration
+		// Allow sharing code that does not expire.
+		AllowPermanent bool
+		// Maximum number of tabs in a shared session, unbounded if zero.
+		MaxTabs int
 	}
 }
 
+// Backend is a storage backend of the compilation cache or shared code.
+type Backend string
+
+const (
+	BackendBolt   Backend = "bolt"   // bbolt database file in the data directory.
+	BackendMemory Backend = "memory" // In-memory LRU cache.
+	BackendFS     Backend = "fs"     // Files in the data directory named by their keys.
+)
+
 // Read reads configuration options from available sources.
 func Read() (*Config, error) {
 	viper.MustBindEnv("Listen", "GOCE_LISTEN")
 	viper.MustBindEnv("CompilationCacheTTL", "GOCE_COMPILATION_CACHE_TTL")
 	viper.MustBindEnv("SharedCodeTTL", "GOCE_SHARED_CODE_TTL")
+	viper.MustBindEnv("AdminToken", "GOCE_ADMIN_TOKEN")
 	viper.MustBindEnv("Compilers.SearchGoPath", "GOCE_COMPILERS_SEARCH_GO_PATH")
 	viper.MustBindEnv("Compilers.SearchSDKPath", "GOCE_COMPILERS_SEARCH_SDK_PATH")
 	viper.MustBindEnv("Compilers.LocalCompilers", "GOCE_COMPILERS_LOCAL_COMPILERS")
 	viper.MustBindEnv("Compilers.AdditionalArchitectures", "GOCE_COMPILERS_ADDITIONAL_ARCHITECTURES")
 	viper.MustBindEnv("Compilers.EnableModules", "GOCE_COMPILERS_ENABLE_MODULES")
+	viper.MustBindEnv("Compilers.MaxConcurrent", "GOCE_COMPILERS_MAX_CONCURRENT")
 	viper.MustBindEnv("Cache.Enabled", "GOCE_CACHE_ENABLED")
+	viper.MustBindEnv("Cache.Backend", "GOCE_CACHE_BACKEND")
+	viper.MustBindEnv("Cache.MaxEntries", "GOCE_CACHE_MAX_ENTRIES")
+	viper.MustBindEnv("Cache.MaxSize", "GOCE_CACHE_MAX_SIZE")
+	viper.MustBindEnv("Cache.Eviction", "GOCE_CACHE_EVICTION")
+	viper.MustBindEnv("Cache.Compression", "GOCE_CACHE_COMPRESSION")
+	viper.MustBindEnv("SharedCode.Backend", "GOCE_SHARED_CODE_BACKEND")
+	viper.MustBindEnv("SharedCode.MaxEntries", "GOCE_SHARED_CODE_MAX_ENTRIES")
+	viper.MustBindEnv("SharedCode.MinTTL", "GOCE_SHARED_CODE_MIN_TTL")
+	viper.MustBindEnv("SharedCode.MaxTTL", "GOCE_SHARED_CODE_MAX_TTL")
+	viper.MustBindEnv("SharedCode.AllowPermanent", "GOCE_SHARED_CODE_ALLOW_PERMANENT")
+	viper.MustBindEnv("SharedCode.MaxTabs", "GOCE_SHARED_CODE_MAX_TABS")
 
 	viper.SetDefault("Listen", ":9000")
 	viper.SetDefault("CompilationCacheTTL", 2*time.Hour)
 	viper.SetDefault("SharedCodeTTL", 24*time.Hour)
+	viper.SetDefault("AdminToken", "")
 	viper.SetDefault("Compilers.SearchGoPath", true)
 	viper.SetDefault("Compilers.SearchSDKPath", true)
 	viper.SetDefault("Compilers.LocalCompilers", []string{})
 	viper.SetDefault("Compilers.AdditionalArchitectures", true)
 	viper.SetDefault("Compilers.EnableModules", true)
+	viper.SetDefault("Compilers.MaxConcurrent", 0)
 	viper.SetDefault("Cache.Enabled", true)
+	viper.SetDefault("Cache.Backend", BackendBolt)
+	viper.SetDefault("Cache.MaxEntries", 0)
+	viper.SetDefault("Cache.MaxSize", 0)
+	viper.SetDefault("Cache.Eviction", "lru")
+	viper.SetDefault("Cache.Compression", "zstd")
+	viper.SetDefault("SharedCode.Backend", BackendBolt)
+	viper.SetDefault("SharedCode.MaxEntries", 0)
+	viper.SetDefault("SharedCode.MinTTL", time.Minute)
+	viper.SetDefault("SharedCode.MaxTTL", 30*24*time.Hour)
+	viper.SetDefault("SharedCode.AllowPermanent", true)
+	viper.SetDefault("SharedCode.MaxTabs", 20)
 
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -2,9 +2,12 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -44,6 +47,8 @@ func main() {
 		ReadTimeout:           3 * time.Second,
 		WriteTimeout:          3 * time.Second,
 		IdleTimeout:           30 * time.Second,
+		// Bodies over the body limit are streamed, see sanityCheck.
+		StreamRequestBody: true,
 	})
 
 	app.Use(fiberzerolog.New(fiberzerolog.Config{
@@ -63,7 +68,6 @@ func main() {
 	app.Use(cors.New())
 	app.Use(compress.New())
 	app.Use(etag.New(etag.Config{Weak: true}))
-	app.Use(sanityCheck())
 
 	compilersSvc, err := compilers.New(&compilers.Config{
 		SearchGoPath:            cfg.Compilers.SearchGoPath,
@@ -81,20 +85,43 @@ func main() {
 		log.Warn().Err(err).Msg("can't create data directory")
 	}
 
-	var compilationCache *cache.Cache[store.CompilationCacheKey, store.CompilationCacheValue]
+	var compilationCache *store.CompilationCache
 	if cfg.Cache.Enabled {
-		compilationCache, err = store.NewCompilationCache("data/cache.db")
+		eviction := cache.Eviction(cfg.Cache.Eviction)
+		if eviction != cache.EvictLRU && eviction != cache.EvictLFU {
+			log.Error().Str("eviction", cfg.Cache.Eviction).Msg("unknown cache eviction policy")
+			os.Exit(1)
+		}
+		compression := cache.Compression(cfg.Cache.Compression)
+		switch compression {
+		case cache.CompressionNone, cache.CompressionZstd, cache.CompressionBrotli:
+		default:
+			log.Error().Str("compression", cfg.Cache.Compression).Msg("unknown cache compression")
+			os.Exit(1)
+		}
+		backend, err := openBackend(cfg.Cache.Backend, "cache", cfg.CompilationCacheTTL, cfg.Cache.MaxEntries, cfg.Cache.MaxSize)
 		if err != nil {
 			log.Error().Err(err).Msg("compilation cache failed")
 			os.Exit(1)
 		}
+		compilationCache = store.NewCompilationCache(backend,
+			cache.WithMaxEntries[store.CompilationCacheKey, store.CompilationCacheValue](cfg.Cache.MaxEntries),
+			cache.WithMaxSize[store.CompilationCacheKey, store.CompilationCacheValue](cfg.Cache.MaxSize),
+			cache.WithEviction[store.CompilationCacheKey, store.CompilationCacheValue](eviction),
+			cache.WithCompression[store.CompilationCacheKey, store.CompilationCacheValue](compression),
+			// Parsing results can change between versions.
+			cache.WithVersion[store.CompilationCacheKey, store.CompilationCacheValue](version),
+		)
 	}
 
-	sharedCodeStore, err := store.NewSharedCode("data/shared.db")
+	sharedCodeBackend, err := openBackend(cfg.SharedCode.Backend, "shared", cfg.SharedCodeTTL, cfg.SharedCode.MaxEntries, 0)
 	if err != nil {
 		log.Error().Err(err).Msg("shared code store failed")
 		os.Exit(1)
 	}
+	sharedCodeStore := store.NewSharedCode(sharedCodeBackend,
+		cache.WithMaxEntries[store.SharedCodeKey, store.SharedCodeValue](cfg.SharedCode.MaxEntries),
+	)
 
 	api := &api.API{
 		Config: cfg,
@@ -104,11 +131,28 @@ func main() {
 		SharedCodeStore:  sharedCodeStore,
 	}
 
+	app.Use(sanityCheck(api.IsAdmin))
+
 	app.Get("/api/compilers", api.GetCompilers)
 	app.Post("/api/format", api.Format)
 	app.Post("/api/compile", api.Compile)
+	app.Post("/api/diff", api.Diff)
+	app.Post("/api/bisect", api.Bisect)
+	app.Post("/api/batch", api.Batch)
 	app.Post("/api/shared", api.ShareCode)
 	app.Get("/api/shared/:id", api.GetSharedCode)
+	app.Get("/api/shared/:id/info", api.GetSharedCodeInfo)
+	app.Delete("/api/shared/:id", api.UnshareCode)
+	app.Get("/api/oembed", api.OEmbed)
+	app.Get("/embed/:id", api.Embed)
+
+	admin := app.Group("/api/admin", api.RequireAdmin())
+	admin.Get("/stats", api.Stats)
+	admin.Post("/cache/purge", api.PurgeCache)
+	admin.Post("/cache/invalidate", api.InvalidateCompiler)
+	admin.Delete("/shared/:id", api.DeleteSharedCode)
+	admin.Get("/shared/export", api.ExportSharedCode)
+	admin.Post("/shared/import", api.ImportSharedCode)
 
 	app.Use("/", serveUI())
 
@@ -138,6 +182,22 @@ func main() {
 	<-doneCh
 }
 
+// openBackend opens a storage backend named name in the data directory.
+// openBackend opens the storage backend by its name. Values of older bolt databases that
+// lost their expiry are given ttl, see [cache.NewBolt]. The in-memory backend is bounded by
+// maxEntries and maxSize itself, so that it does not outgrow the cache limits.
+func openBackend(backend config.Backend, name string, ttl time.Duration, maxEntries int, maxSize int64) (cache.Backend, error) {
+	switch backend {
+	case config.BackendBolt:
+		return cache.NewBolt(filepath.Join("data", name+".db"), ttl)
+	case config.BackendMemory:
+		return cache.NewMemory(maxEntries, maxSize), nil
+	case config.BackendFS:
+		return cache.NewFS(filepath.Join("data", name))
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
+
 func serveUI() fiber.Handler {
 	return filesystem.New(filesystem.Config{
 		Root:         http.FS(ui.DistFS),
@@ -148,17 +208,35 @@ func serveUI() fiber.Handler {
 	})
 }
 
-func sanityCheck() fiber.Handler {
+func sanityCheck(isAdmin func(ctx *fiber.Ctx) bool) fiber.Handler {
 	const maxContentLength = 64 << 10
+	// Time to read an import of shared code, which is longer than the read timeout.
+	const importTimeout = 10 * time.Minute
 	errInsane := fiber.NewError(fiber.StatusBadRequest, "request too long")
 
 	return func(ctx *fiber.Ctx) error {
+		// Imports of shared code by admins are streamed and not limited.
+		if ctx.Path() == "/api/admin/shared/import" && isAdmin(ctx) {
+			if err := ctx.Context().Conn().SetReadDeadline(time.Now().Add(importTimeout)); err != nil {
+				return err
+			}
+			return ctx.Next()
+		}
+
 		if ctx.Request().Header.ContentLength() > maxContentLength {
 			return errInsane
 		}
 
-		if len(ctx.Request().Body()) > maxContentLength {
-			return errInsane
+		// Read at most the limit of streamed bodies of unknown length.
+		if stream := ctx.Context().RequestBodyStream(); stream != nil {
+			body, err := io.ReadAll(io.LimitReader(stream, maxContentLength+1))
+			if err != nil {
+				return err
+			}
+			if len(body) > maxContentLength {
+				return errInsane
+			}
+			ctx.Request().SetBody(body)
 		}
 
 		return ctx.Next()
@@ -0,0 +1,189 @@
+package parsers
+
+import (
+	"sort"
+	"strings"
+)
+
+// Allocations summarizes heap allocations of a function.
+type Allocations struct {
+	HeapEscapes int              `json:"heapEscapes"` // Values escaping to heap.
+	Calls       int              `json:"calls"`       // Calls of allocating runtime functions.
+	Sites       []AllocationSite `json:"sites,omitempty"`
+	ZeroAlloc   bool             `json:"zeroAlloc"`
+}
+
+// AllocationSite is a place in the source code where the function allocates.
+type AllocationSite struct {
+	Kind AllocationKind `json:"kind"`
+	Line int            `json:"line"`
+	// Escaping value or runtime function called.
+	Name string `json:"name"`
+	// Assembly line of the call.
+	AssemblyLine int `json:"assembly,omitempty"`
+}
+
+type AllocationKind string
+
+const (
+	AllocationHeapEscape AllocationKind = "heapEscape"
+	AllocationCall       AllocationKind = "call"
+)
+
+// summarizeAllocations fills allocation summaries of res.Functions, which must
+// correspond to the functions found in the assembly.
+//
+// Allocating runtime calls are attributed to the function containing them. Heap escapes are
+// attributed to the function they are reported in, if any, or to the innermost function
+// declared around their source position.
+func summarizeAllocations(res *Result, functions []asmFunction) {
+	for i, fn := range functions {
+		allocs := &res.Functions[i].Allocations
+		for j, code := range fn.Code {
+			callee, ok := strings.CutPrefix(code, "CALL runtime.")
+			if !ok {
+				continue
+			}
+			callee = strings.TrimSuffix(callee, "(SB)")
+			if !isAllocatingCall(callee) {
+				continue
+			}
+			allocs.Calls++
+			allocs.Sites = append(allocs.Sites, AllocationSite{
+				Kind:         AllocationCall,
+				Line:         fn.Lines[j],
+				Name:         "runtime." + callee,
+				AssemblyLine: fn.AssemblyStart + j,
+			})
+		}
+	}
+
+	spans := functionSpans(functions)
+	bySymbol := map[string]int{}
+	for i, fn := range functions {
+		bySymbol[fn.Symbol] = i
+	}
+	for _, d := range res.Diagnostics {
+		var line int
+		var name, function string
+		switch d := d.(type) {
+		case HeapEscape:
+			line, name, function = d.Range.Start.Line, d.Name, d.Function
+			if name == "" {
+				name = d.Message
+				if match := reEscapesToHeap.FindStringSubmatch(d.Message); match != nil {
+					name = match[reEscapesToHeap_Name]
+				}
+			}
+		case FuncLiteral:
+			if !d.Escapes {
+				continue
+			}
+			line, name = d.Range.Start.Line, "func literal"
+		default:
+			continue
+		}
+		i, ok := bySymbol["main."+function]
+		if !ok {
+			i, ok = spans.find(line)
+		}
+		if !ok {
+			continue
+		}
+		allocs := &res.Functions[i].Allocations
+		site := AllocationSite{Kind: AllocationHeapEscape, Line: line, Name: name}
+		// Heap escapes can be reported more than once for the same position.
+		if containsSite(allocs.Sites, site) {
+			continue
+		}
+		allocs.HeapEscapes++
+		allocs.Sites = append(allocs.Sites, site)
+	}
+
+	for i := range res.Functions {
+		allocs := &res.Functions[i].Allocations
+		sort.SliceStable(allocs.Sites, func(a, b int) bool { return allocs.Sites[a].Line < allocs.Sites[b].Line })
+		allocs.ZeroAlloc = allocs.HeapEscapes == 0 && allocs.Calls == 0
+	}
+}
+
+func containsSite(sites []AllocationSite, site AllocationSite) bool {
+	for _, s := range sites {
+		if s == site {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllocatingCall reports whether the runtime function allocates on the heap.
+func isAllocatingCall(name string) bool {
+	switch name {
+	case "newobject", "makeslice", "makeslicecopy", "growslice",
+		"makemap", "makemap_small", "makechan",
+		"slicebytetostring", "stringtoslicebyte", "stringtoslicerune", "slicerunetostring", "intstring":
+		return true
+	}
+	for _, prefix := range []string{"mallocgc", "convT", "concatstring"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// functionSpan is a range of source lines of a function declaration.
+type functionSpan struct {
+	index      int
+	start, end int
+}
+
+type spanList []functionSpan
+
+// functionSpans estimates source spans of functions from the source lines of their instructions.
+// A function ends before the next declared top-level function, closures are nested in their parents.
+func functionSpans(functions []asmFunction) spanList {
+	var topLevel []int
+	for _, fn := range functions {
+		if fn.SourceLine > 0 && !isClosureName(baseFunctionName(fn.Symbol)) {
+			topLevel = append(topLevel, fn.SourceLine)
+		}
+	}
+	sort.Ints(topLevel)
+
+	var spans spanList
+	for i, fn := range functions {
+		if fn.SourceLine == 0 {
+			continue
+		}
+		limit := int(^uint(0) >> 1)
+		if j := sort.SearchInts(topLevel, fn.SourceLine+1); j < len(topLevel) {
+			limit = topLevel[j]
+		}
+		span := functionSpan{index: i, start: fn.SourceLine, end: fn.SourceLine}
+		for _, line := range fn.Lines {
+			if line > span.end && line < limit {
+				span.end = line
+			}
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// find returns the index of the innermost function containing the line.
+func (spans spanList) find(line int) (int, bool) {
+	best := -1
+	for i, s := range spans {
+		if line < s.start || line > s.end {
+			continue
+		}
+		if best == -1 || s.start > spans[best].start || (s.start == spans[best].start && s.end < spans[best].end) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return 0, false
+	}
+	return spans[best].index, true
+}
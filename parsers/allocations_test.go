@@ -0,0 +1,78 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeAllocations(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"type node struct{ next *node }",
+		"",
+		"func push(n *node) *node {",
+		"\treturn &node{next: n}",
+		"}",
+		"",
+		"func square(x int) int {",
+		"\treturn x * x",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"./main.go:6:9: &node{...} escapes to heap in push:",
+		"./main.go:6:9: &node{...} escapes to heap in push:",
+		"main.push STEXT size=64 args=0x8 locals=0x18 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:5)\tTEXT\tmain.push(SB), ABIInternal, $24-8",
+		"\t0x000e 00014 (./main.go:6)\tLEAQ\ttype:main.node(SB), AX",
+		"\t0x0015 00021 (./main.go:6)\tCALL\truntime.newobject(SB)",
+		"\t0x0020 00032 (./main.go:6)\tRET",
+		"main.square STEXT nosplit size=5 args=0x8 locals=0x0 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:9)\tTEXT\tmain.square(SB), NOSPLIT|ABIInternal, $0-8",
+		"\t0x0000 00000 (./main.go:10)\tIMULQ\tAX, AX",
+		"\t0x0004 00004 (./main.go:10)\tRET",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	if len(res.Functions) != 2 {
+		t.Fatalf("expected 2 functions, got %d", len(res.Functions))
+	}
+	want := Allocations{
+		HeapEscapes: 1,
+		Calls:       1,
+		Sites: []AllocationSite{
+			{Kind: AllocationCall, Line: 6, Name: "runtime.newobject", AssemblyLine: 3},
+			{Kind: AllocationHeapEscape, Line: 6, Name: "&node{...}"},
+		},
+	}
+	if got := res.Functions[0].Allocations; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected push allocations %+v, got %+v", want, got)
+	}
+	if got := res.Functions[1].Allocations; !got.ZeroAlloc || len(got.Sites) != 0 {
+		t.Errorf("expected square to not allocate, got %+v", got)
+	}
+}
+
+func TestIsAllocatingCall(t *testing.T) {
+	tests := map[string]bool{
+		"newobject":              true,
+		"growslice":              true,
+		"makemap_small":          true,
+		"convT64":                true,
+		"concatstring3":          true,
+		"mallocgcSmallNoScanSC2": true,
+		"slicebytetostringtmp":   false,
+		"morestack_noctxt":       false,
+		"gcWriteBarrier2":        false,
+		"panicIndex":             false,
+	}
+	for name, want := range tests {
+		if got := isAllocatingCall(name); got != want {
+			t.Errorf("isAllocatingCall(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package parsers
+
+import (
+	"bytes"
+	"strings"
+)
+
+// findWriteBarriers finds write barrier checks of the garbage collector guarding pointer stores
+// and marks the source assignments responsible for them, one per source line.
+//
+// Pointer stores load the runtime.writeBarrier flag, e.g. "CMPL runtime.writeBarrier(SB), $0"
+// on amd64 or "MOVWU runtime.writeBarrier(SB), R3" on arm64, and call runtime.gcWriteBarrier*
+// to record pointers while the garbage collector is marking.
+func findWriteBarriers(functions []asmFunction, sourceLines [][]byte) []WriteBarrier {
+	var barriers []WriteBarrier
+	byLine := map[int]int{}
+	for _, fn := range functions {
+		for i, code := range fn.Code {
+			check := strings.Contains(code, "runtime.writeBarrier(SB)")
+			call := isWriteBarrierCall(code)
+			if !check && !call || fn.Lines[i] == 0 {
+				continue
+			}
+			idx, ok := byLine[fn.Lines[i]]
+			if !ok {
+				line, ok := sourceLine(sourceLines, fn.Lines[i])
+				if !ok {
+					continue
+				}
+				idx = len(barriers)
+				byLine[fn.Lines[i]] = idx
+				start, length := assignmentRange(line)
+				barriers = append(barriers, WriteBarrier{
+					Diagnostic: Diagnostic{
+						Type:  DiagnosticWriteBarrier,
+						Range: makeRange(locationToUnicode(sourceLines, Location{Line: fn.Lines[i], Column: start}), length),
+					},
+				})
+			}
+			b := &barriers[idx]
+			if len(b.Functions) == 0 || b.Functions[len(b.Functions)-1] != fn.Symbol {
+				b.Functions = append(b.Functions, fn.Symbol)
+			}
+			if check {
+				b.Checks++
+			}
+			if call {
+				b.Calls++
+			}
+		}
+	}
+	return barriers
+}
+
+// isWriteBarrierCall reports whether the instruction calls a write barrier of the runtime,
+// e.g. "CALL runtime.gcWriteBarrier2(SB)", or bulk barriers for typed memory moves and clears.
+func isWriteBarrierCall(code string) bool {
+	callee, ok := strings.CutPrefix(code, "CALL runtime.")
+	if !ok {
+		return false
+	}
+	return strings.HasPrefix(callee, "gcWriteBarrier") ||
+		strings.HasPrefix(callee, "wbMove(") || strings.HasPrefix(callee, "wbZero(")
+}
+
+// assignmentRange returns the column and length of the left-hand side of the assignment on the line,
+// or of the whole statement if there is no assignment.
+func assignmentRange(line []byte) (int, int) {
+	indent := len(line) - len(bytes.TrimLeft(line, " \t"))
+	stmt := bytes.TrimSpace(line)
+	start, end := 0, len(stmt)
+	for i := 1; i < len(stmt); i++ {
+		if stmt[i] != '=' || (i+1 < len(stmt) && stmt[i+1] == '=') {
+			continue
+		}
+		// Skip comparisons, but not shift assignments.
+		if prev := stmt[i-1]; prev == '!' || prev == '=' || ((prev == '<' || prev == '>') && (i < 2 || stmt[i-2] != prev)) {
+			continue
+		}
+		end = len(bytes.TrimRight(stmt[:i], " \t:+-*/%&|^<>"))
+		break
+	}
+	if bytes.HasPrefix(stmt[:end], []byte("var ")) {
+		start = len("var ")
+	}
+	return indent + start + 1, end - start
+}
+
+// findStackChecks marks declarations of functions having a stack bound check in the prologue,
+// which is also where goroutines are preempted synchronously.
+func findStackChecks(functions []asmFunction, sourceLines [][]byte) []StackCheck {
+	var checks []StackCheck
+	seen := map[int]bool{}
+	for _, fn := range functions {
+		if fn.SourceLine == 0 || seen[fn.SourceLine] {
+			continue
+		}
+		if _, ok := findStackSplit(fn); !ok {
+			continue
+		}
+		// Package initialization is reported at variable declarations.
+		line, ok := sourceLine(sourceLines, fn.SourceLine)
+		if !ok || !bytes.Contains(line, []byte("func")) {
+			continue
+		}
+		seen[fn.SourceLine] = true
+
+		d := StackCheck{
+			Diagnostic: Diagnostic{Type: DiagnosticStackCheck},
+			Function:   fn.Symbol,
+			Frame:      fn.Frame,
+		}
+		for _, code := range fn.Code {
+			if callee, ok := strings.CutPrefix(code, "CALL runtime.morestack"); ok {
+				d.Morestack = "runtime.morestack" + strings.TrimSuffix(callee, "(SB)")
+				break
+			}
+		}
+		location, length := funcNameRange(line, Location{Line: fn.SourceLine, Column: 1}, strings.TrimPrefix(fn.Symbol, "main."))
+		d.Range = makeRange(locationToUnicode(sourceLines, location), length)
+		checks = append(checks, d)
+	}
+	return checks
+}
@@ -0,0 +1,88 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindWriteBarriers(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"type node struct{ next *node }",
+		"",
+		"var head *node",
+		"",
+		"func push() {",
+		"\thead = &node{next: head}",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"main.push STEXT size=96 args=0x0 locals=0x18 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:7)\tTEXT\tmain.push(SB), ABIInternal, $24-0",
+		"\t0x0000 00000 (./main.go:7)\tCMPQ\tSP, 16(R14)",
+		"\t0x0004 00004 (./main.go:7)\tJLS\t80",
+		"\t0x0006 00006 (./main.go:7)\tPUSHQ\tBP",
+		"\t0x000e 00014 (./main.go:8)\tCALL\truntime.newobject(SB)",
+		"\t0x0013 00019 (./main.go:8)\tCMPL\truntime.writeBarrier(SB), $0",
+		"\t0x001a 00026 (./main.go:8)\tJEQ\t40",
+		"\t0x001c 00028 (./main.go:8)\tCALL\truntime.gcWriteBarrier2(SB)",
+		"\t0x0028 00040 (./main.go:8)\tMOVQ\tAX, main.head(SB)",
+		"\t0x0034 00052 (./main.go:9)\tRET",
+		"\t0x0050 00080 (./main.go:7)\tCALL\truntime.morestack_noctxt(SB)",
+		"\t0x0055 00085 (./main.go:7)\tJMP\t0",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	var got []IDiagnostic
+	for _, d := range res.Diagnostics {
+		switch d.(type) {
+		case WriteBarrier, StackCheck:
+			got = append(got, d)
+		}
+	}
+	want := []IDiagnostic{
+		WriteBarrier{
+			Diagnostic: Diagnostic{Type: DiagnosticWriteBarrier, Range: Range{Location{8, 2}, Location{8, 6}}},
+			Functions:  []string{"main.push"},
+			Checks:     1,
+			Calls:      1,
+		},
+		StackCheck{
+			Diagnostic: Diagnostic{Type: DiagnosticStackCheck, Range: Range{Location{7, 6}, Location{7, 10}}},
+			Function:   "main.push",
+			Frame:      24,
+			Morestack:  "runtime.morestack_noctxt",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected diagnostics\n%+v\ngot\n%+v", want, got)
+	}
+}
+
+func TestAssignmentRange(t *testing.T) {
+	tests := []struct {
+		line   string
+		column int
+		length int
+	}{
+		{"\thead = &node{next: head}", 2, 4},
+		{"\ts.items = append(s.items, v)", 2, 7},
+		{"var s = make([]int, 100)", 5, 1},
+		{"\tp := &x", 2, 1},
+		{"\tm[k] += 1", 2, 4},
+		{"\tx <<= 2", 2, 1},
+		{"\tif a == b || c <= d {", 2, 21},
+		{"\tsink(p)", 2, 7},
+	}
+	for _, tt := range tests {
+		column, length := assignmentRange([]byte(tt.line))
+		if column != tt.column || length != tt.length {
+			t.Errorf("assignmentRange(%q) = %d, %d, want %d, %d", tt.line, column, length, tt.column, tt.length)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package parsers
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// parseClosureCapture parses closure capture messages, e.g.
+// "main capturing by ref: total (addr=false assign=true width=8)".
+func parseClosureCapture(text []byte) (ClosureCapture, bool) {
+	match := reCapturing.FindSubmatch(text)
+	if match == nil {
+		return ClosureCapture{}, false
+	}
+	width, ok := parseInt(match[reCapturing_Width])
+	if !ok {
+		return ClosureCapture{}, false
+	}
+	return ClosureCapture{
+		Diagnostic: Diagnostic{Type: DiagnosticClosureCapture},
+		Name:       string(match[reCapturing_Name]),
+		Function:   string(match[reCapturing_Function]),
+		Mode:       CaptureMode(match[reCapturing_Mode]),
+		Addr:       string(match[reCapturing_Addr]) == "true",
+		Assign:     string(match[reCapturing_Assign]) == "true",
+		Width:      width,
+	}, true
+}
+
+// nameRange returns the range of the name at or after the location on the line.
+func nameRange(line []byte, location Location, name string) (Location, int) {
+	col := location.Column
+	if col < 1 || name == "" {
+		return location, 1
+	}
+	if bytes.HasPrefix(line[col-1:], []byte(name)) {
+		return location, len(name)
+	}
+	if pos := bytes.Index(line[col-1:], []byte(name)); pos != -1 {
+		return Location{Line: location.Line, Column: col + pos}, len(name)
+	}
+	if pos := bytes.Index(line, []byte(name)); pos != -1 {
+		return Location{Line: location.Line, Column: pos + 1}, len(name)
+	}
+	return location, 1
+}
+
+// funcNameRange returns the range of the function name reported at location. Methods are reported
+// at the receiver, e.g. "(*T).M", and closures at the func keyword, e.g. "main.func1".
+func funcNameRange(line []byte, location Location, name string) (Location, int) {
+	name = baseFunctionName(name)
+	if isClosureName(name) {
+		return nameRange(line, location, "func")
+	}
+	if i := strings.LastIndexByte(name, '.'); i != -1 {
+		name = name[i+1:]
+	}
+	return nameRange(line, location, name)
+}
+
+// isClosureName reports whether the name is of a compiler generated closure function,
+// e.g. "main.func1", "makeAdder.func1.2" or "main.func1.func2".
+func isClosureName(name string) bool {
+	return reClosureName.MatchString(name)
+}
+
+var reCapturing = regexp.MustCompile(`^(\S+) capturing by (value|ref): (\S+) \(addr=(\w+) assign=(\w+) width=(\d+)\)`)
+
+const (
+	reCapturing_Function = iota + 1
+	reCapturing_Mode
+	reCapturing_Name
+	reCapturing_Addr
+	reCapturing_Assign
+	reCapturing_Width
+)
+
+var reFuncLiteral = regexp.MustCompile(`^func literal (escapes to heap|does not escape)$`)
+
+const (
+	reFuncLiteral_Escape = iota + 1
+)
+
+var reClosureName = regexp.MustCompile(`(?:^|\.)func\d+(?:\.\d+)*$`)
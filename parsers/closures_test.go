@@ -0,0 +1,100 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseClosures(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"type counter struct{ n int }",
+		"",
+		"func (c *counter) inc() { c.n++ }",
+		"",
+		"func main() {",
+		"\ttotal := 0",
+		"\tf := func(x int) { total += x }",
+		"\tf(1)",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"./main.go:5:6: can inline (*counter).inc with cost 4 as: method(*counter) func() { c.n++ }",
+		"./main.go:9:7: can inline main.func1 with cost 7 as: func(int) { total += x }",
+		"./main.go:7:6: cannot inline main.func1.1: function too complex: cost 100 exceeds budget 80",
+		"./main.go:8:2: main capturing by ref: total (addr=false assign=true width=8)",
+		"./main.go:9:7: func literal escapes to heap:",
+		"./main.go:9:7: func literal does not escape",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	want := []IDiagnostic{
+		InliningAnalysis{
+			Diagnostic: Diagnostic{Type: DiagnosticInliningAnalysis, Range: Range{Location{5, 19}, Location{5, 22}}},
+			Name:       "(*counter).inc",
+			CanInline:  true,
+			Cost:       4,
+			Budget:     80,
+		},
+		InliningAnalysis{
+			Diagnostic: Diagnostic{Type: DiagnosticInliningAnalysis, Range: Range{Location{9, 7}, Location{9, 11}}},
+			Name:       "main.func1",
+			CanInline:  true,
+			Cost:       7,
+			Budget:     80,
+		},
+		InliningAnalysis{
+			Diagnostic: Diagnostic{Type: DiagnosticInliningAnalysis, Range: Range{Location{7, 1}, Location{7, 5}}},
+			Name:       "main.func1.1",
+			Reason:     "function too complex: cost 100 exceeds budget 80",
+			Cost:       100,
+			Budget:     80,
+		},
+		ClosureCapture{
+			Diagnostic: Diagnostic{Type: DiagnosticClosureCapture, Range: Range{Location{8, 2}, Location{8, 7}}},
+			Name:       "total",
+			Function:   "main",
+			Mode:       CaptureByRef,
+			Assign:     true,
+			Width:      8,
+		},
+		FuncLiteral{
+			Diagnostic: Diagnostic{Type: DiagnosticFuncLiteral, Range: Range{Location{9, 7}, Location{9, 11}}},
+			Escapes:    false,
+		},
+	}
+	if len(res.Diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %+v", len(want), len(res.Diagnostics), res.Diagnostics)
+	}
+	for i := range want {
+		if ia, ok := res.Diagnostics[i].(InliningAnalysis); ok {
+			// Cost breakdown is covered by TestAttributeInlineCosts.
+			ia.Breakdown = nil
+			res.Diagnostics[i] = ia
+		}
+		if !reflect.DeepEqual(res.Diagnostics[i], want[i]) {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, want[i], res.Diagnostics[i])
+		}
+	}
+}
+
+func TestIsClosureName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"main.func1":        true,
+		"makeAdder.func1.2": true,
+		"main.func1.func2":  true,
+		"func1":             true,
+		"funcName":          false,
+		"(*T).func1x":       false,
+		"main":              false,
+	} {
+		if got := isClosureName(name); got != want {
+			t.Errorf("isClosureName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
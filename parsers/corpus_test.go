@@ -0,0 +1,111 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w1ck3dg0ph3r/goce/compilers"
+)
+
+var update = flag.Bool("update", false, "update golden files of the parser corpus")
+
+// TestCorpus parses compiler output captured by cmd/gencorpus and compares
+// the results with golden files.
+//
+// Run with -update to regenerate golden files after changing the parser.
+func TestCorpus(t *testing.T) {
+	snippets, err := filepath.Glob("testdata/corpus/*/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) == 0 {
+		t.Fatal("corpus is empty")
+	}
+	for _, snippet := range snippets {
+		snippetDir := filepath.Dir(snippet)
+		code, err := os.ReadFile(snippet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(snippetDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			caseDir := filepath.Join(snippetDir, e.Name())
+			t.Run(filepath.Base(snippetDir)+"/"+e.Name(), func(t *testing.T) {
+				testCorpusCase(t, caseDir, code)
+			})
+		}
+	}
+}
+
+func testCorpusCase(t *testing.T, dir string, code []byte) {
+	info, err := parseCaseDirName(filepath.Base(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildOutput, err := os.ReadFile(filepath.Join(dir, "buildoutput"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildJSON, err := os.ReadFile(filepath.Join(dir, "build.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := compilers.Result{
+		CompilerInfo:   info,
+		SourceFilename: "main.go",
+		SourceCode:     code,
+		BuildOutput:    buildOutput,
+		BuildJSON:      buildJSON,
+	}
+	parser := FindMatching(output)
+	if parser == nil {
+		t.Fatalf("parser not found for go version: %s", info.Version)
+	}
+	got, err := json.MarshalIndent(parser.Parse(output), "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	goldenFilename := filepath.Join(dir, "result.json")
+	if *update {
+		if err := os.WriteFile(goldenFilename, got, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(goldenFilename)
+	if err != nil {
+		t.Fatalf("read golden file: %v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("result differs from %s, run with -update and review the diff", goldenFilename)
+	}
+}
+
+// parseCaseDirName parses names produced by cmd/gencorpus, e.g. go1.24.6_linux_amd64.
+func parseCaseDirName(name string) (compilers.CompilerInfo, error) {
+	parts := strings.Split(name, "_")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "go") {
+		return compilers.CompilerInfo{}, errors.New("invalid corpus case directory: " + name)
+	}
+	return compilers.CompilerInfo{
+		Version:      strings.TrimPrefix(parts[0], "go"),
+		Platform:     parts[1],
+		Architecture: parts[2],
+	}, nil
+}
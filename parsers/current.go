@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"regexp"
 	"strconv"
@@ -25,28 +26,52 @@ func (currentParser) Parse(output compilers.Result) Result {
 
 func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 	sc := bufio.NewScanner(output)
+	sc.Buffer(nil, maxBuildOutputLineLength)
 
-	mainFilenameBytes := []byte("./main.go")
 	sourceLines := bytes.Split(sourceCode, []byte{'\n'})
+	directives := parseLineDirectives(sourceLines)
 
 	buildOutput := &strings.Builder{}
 	assembly := strings.Builder{}
 	lastSourceLine := 0
 	assemblyLine := 0
+	outputLine := 0
+
+	var functions []asmFunction
+	headers := map[string]stextHeader{}
+	// Prove pass reports the same facts for every copy of inlined code.
+	proved := map[Prove]bool{}
+	generics := genericsInfo{
+		dictionaryEntries: map[string]int{},
+		wrapperCalls:      map[string]dictionaryCall{},
+		dictionarySizes:   map[string]int{},
+	}
 
 	for sc.Scan() {
-		if err := sc.Err(); err != nil {
-			break
-		}
-
+		outputLine++
 		line := sc.Bytes()
 		if len(line) == 0 || isComment(line) {
 			continue
 		}
 
+		warn := func(message string) {
+			res.Warnings = append(res.Warnings, Warning{
+				Source:  WarningSourceBuildOutput,
+				Line:    outputLine,
+				Text:    string(line),
+				Message: message,
+			})
+		}
+
 		var match [][]byte
 
-		if bytes.Contains(line, []byte(" STEXT ")) {
+		if name, header, ok := parseSTEXTHeader(line); ok {
+			headers[name] = header
+			continue
+		}
+
+		if name, size, ok := parseDictionarySymbol(line); ok {
+			generics.dictionarySizes[name] = size
 			continue
 		}
 
@@ -57,20 +82,58 @@ func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 			assembly.Write(match[reAssembly_Code])
 			assembly.WriteRune('\n')
 			assemblyLine++
-			if bytes.Equal(match[reAssembly_File], mainFilenameBytes) {
-				lineNumber, _ := strconv.Atoi(string(match[reAssembly_Line]))
-				if lineNumber != lastSourceLine {
-					res.Mapping = append(res.Mapping, Mapping{
-						SourceLine:    lineNumber,
-						AssemblyStart: assemblyLine,
-						AssemblyEnd:   assemblyLine,
-					})
-				} else {
-					lastMapping := &res.Mapping[len(res.Mapping)-1]
-					lastMapping.AssemblyEnd = assemblyLine
+			if fn, ok := parseTextDirective(string(match[reAssembly_Code])); ok {
+				if len(functions) > 0 {
+					functions[len(functions)-1].AssemblyEnd = assemblyLine - 1
+				}
+				fn.AssemblyStart = assemblyLine
+				functions = append(functions, fn)
+			}
+			if len(functions) > 0 {
+				fn := &functions[len(functions)-1]
+				fn.Code = append(fn.Code, string(match[reAssembly_Code]))
+				fn.Lines = append(fn.Lines, 0)
+				address, _ := strconv.ParseInt(string(match[reAssembly_Address]), 0, 64)
+				fn.Addresses = append(fn.Addresses, int(address))
+			}
+			if len(match[reAssembly_Line]) == 0 {
+				continue
+			}
+			lineNumber, ok := parseInt(match[reAssembly_Line])
+			physLineNumber := 0
+			if len(match[reAssembly_PhysLine]) > 0 {
+				physLineNumber, ok = parseInt(match[reAssembly_PhysLine])
+			}
+			if !ok {
+				warn("invalid assembly source line")
+				continue
+			}
+			location, origin, ok := directives.resolvePhysical(
+				match[reAssembly_File], lineNumber,
+				match[reAssembly_PhysFile], physLineNumber,
+			)
+			if !ok {
+				continue
+			}
+			if len(functions) > 0 {
+				fn := &functions[len(functions)-1]
+				if fn.SourceLine == 0 {
+					fn.SourceLine = location.Line
 				}
-				lastSourceLine = lineNumber
+				fn.Lines[len(fn.Lines)-1] = location.Line
 			}
+			if location.Line != lastSourceLine || len(res.Mapping) == 0 {
+				res.Mapping = append(res.Mapping, Mapping{
+					SourceLine:    location.Line,
+					AssemblyStart: assemblyLine,
+					AssemblyEnd:   assemblyLine,
+					Origin:        origin,
+				})
+			} else {
+				lastMapping := &res.Mapping[len(res.Mapping)-1]
+				lastMapping.AssemblyEnd = assemblyLine
+			}
+			lastSourceLine = location.Line
 			continue
 		}
 
@@ -78,28 +141,56 @@ func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 			buildOutput.Write(line)
 			buildOutput.WriteByte('\n')
 			fileName := match[reBuildLine_FileName]
-			location := Location{
-				Line:   mustParseInt(match[reBuildLine_Line]),
-				Column: mustParseInt(match[reBuildLine_Column]),
-			}
 			text := match[reBuildLine_Text]
-			if !bytes.Equal(fileName, mainFilenameBytes) {
+			if indentLevel(text) > 0 {
 				continue
 			}
-			if indentLevel(text) > 0 {
+			lineNumber, lineOK := parseInt(match[reBuildLine_Line])
+			columnNumber, columnOK := 0, true
+			if len(match[reBuildLine_Column]) > 0 {
+				columnNumber, columnOK = parseInt(match[reBuildLine_Column])
+			}
+			if !lineOK || !columnOK {
+				warn("invalid source position")
+				continue
+			}
+			location, origin, ok := directives.resolve(fileName, lineNumber, columnNumber)
+			if !ok {
+				continue
+			}
+			line, ok := sourceLine(sourceLines, location.Line)
+			if !ok || location.Column < 0 || location.Column > len(line)+1 {
+				warn("source position out of range")
 				continue
 			}
 
+			// Generic instantiations
+			if match = reCanInlineInstantiation.FindSubmatch(text); match != nil {
+				name := string(match[reCanInlineInstantiation_Name])
+				if n, ok := parseDictionaryEntries(text); ok {
+					generics.dictionaryEntries[name] = n
+				}
+				if call, ok := parseWrapperCall(text); ok {
+					generics.wrapperCalls[name] = call
+				}
+			}
+
 			// Can Inline
 			if match = reCanInline.FindSubmatch(text); match != nil {
 				name := string(match[reCanInline_Name])
+				if location.Column == 0 {
+					location.Column, _ = guessColumn(line, []byte(baseFunctionName(name)))
+				}
+				start, length := funcNameRange(line, location, name)
 				fc := InliningAnalysis{
 					Diagnostic: Diagnostic{
-						Type:  DiagnosticInliningAnalysis,
-						Range: makeRange(locationToUnicode(sourceLines, location), len(name)),
+						Type:   DiagnosticInliningAnalysis,
+						Range:  makeRange(locationToUnicode(sourceLines, start), length),
+						Origin: origin,
 					},
 					Name:      name,
 					CanInline: true,
+					Budget:    inlineBudget,
 				}
 				cost, _ := strconv.Atoi(string(match[reCanInline_Cost]))
 				fc.Cost = cost
@@ -109,23 +200,32 @@ func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 			// Cannot Inline
 			if match = reCannotInline.FindSubmatch(text); match != nil {
 				name := string(match[reCannotInline_Name])
+				if location.Column == 0 {
+					location.Column, _ = guessColumn(line, []byte(baseFunctionName(name)))
+				}
+				start, length := funcNameRange(line, location, name)
 				fc := InliningAnalysis{
 					Diagnostic: Diagnostic{
-						Type:  DiagnosticInliningAnalysis,
-						Range: makeRange(locationToUnicode(sourceLines, location), len(name)),
+						Type:   DiagnosticInliningAnalysis,
+						Range:  makeRange(locationToUnicode(sourceLines, start), length),
+						Origin: origin,
 					},
-					Name:      string(match[reCannotInline_Name]),
+					Name:      name,
 					CanInline: false,
 					Reason:    string(match[reCannotInline_Reason]),
 				}
+				fc.Cost, fc.Budget, _ = parseInlineBudget(match[reCannotInline_Reason])
 				res.Diagnostics = append(res.Diagnostics, fc)
 			}
 
 			// Inlining Call
 			if match = reInliningCall.FindSubmatch(text); match != nil {
-				col := location.Column
-				line := sourceLines[location.Line-1]
 				name := match[reInliningCall_Name]
+				if location.Column == 0 {
+					col, length := guessColumn(line, name)
+					location.Column = min(col+length, len(line)+1)
+				}
+				col := location.Column
 				nameLen := len(name)
 				if bytes.HasSuffix(line[:col-1], name) {
 					col -= nameLen
@@ -143,22 +243,77 @@ func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 								Column: col,
 							},
 						), nameLen),
+						Origin: origin,
 					},
 					Name: string(name),
 				}
 				res.Diagnostics = append(res.Diagnostics, ic)
 			}
 
-			// Heap escapes
-			if match = reEscapesToHeap.FindSubmatch(text); match != nil {
-				line := sourceLines[location.Line-1]
+			// Devirtualization
+			if d, ok := parseDevirtualization(text); ok {
+				if location.Column == 0 {
+					location.Column, _ = guessColumn(line, []byte(d.Call))
+				}
+				start, length := callRange(line, location, d.Call)
+				d.Range = makeRange(locationToUnicode(sourceLines, start), length)
+				d.Origin = origin
+				res.Diagnostics = append(res.Diagnostics, d)
+			}
+
+			// Prove pass
+			if p, ok := parseProve(text); ok && location.Column > 0 {
+				p.Range = makeRange(locationToUnicode(sourceLines, location), expressionLength(line, location.Column))
+				if !proved[p] {
+					proved[p] = true
+					p.Origin = origin
+					res.Diagnostics = append(res.Diagnostics, p)
+				}
+			}
+
+			// Closure captures
+			if c, ok := parseClosureCapture(text); ok {
+				if location.Column == 0 {
+					location.Column = 1
+				}
+				start, length := nameRange(line, location, c.Name)
+				c.Range = makeRange(locationToUnicode(sourceLines, start), length)
+				c.Origin = origin
+				res.Diagnostics = append(res.Diagnostics, c)
+			}
+
+			// Function literals
+			if match = reFuncLiteral.FindSubmatch(text); match != nil {
+				if location.Column == 0 {
+					location.Column = 1
+				}
+				start, length := nameRange(line, location, "func")
+				res.Diagnostics = append(res.Diagnostics, FuncLiteral{
+					Diagnostic: Diagnostic{
+						Type:   DiagnosticFuncLiteral,
+						Range:  makeRange(locationToUnicode(sourceLines, start), length),
+						Origin: origin,
+					},
+					Escapes: string(match[reFuncLiteral_Escape]) == "escapes to heap",
+				})
+			}
+
+			// Heap escapes, function literals are reported separately
+			if match = reEscapesToHeap.FindSubmatch(text); match != nil && string(match[reEscapesToHeap_Name]) != "func literal" {
 				name := match[reEscapesToHeap_Name]
+				function := string(match[reEscapesToHeap_Function])
+				columnKnown := location.Column != 0
+				if !columnKnown {
+					location.Column = 1
+				}
 
 				he := HeapEscape{
 					Diagnostic: Diagnostic{
-						Type:  DiagnosticHeapEscape,
-						Range: makeRange(locationToUnicode(sourceLines, location), 1),
+						Type:   DiagnosticHeapEscape,
+						Range:  makeRange(locationToUnicode(sourceLines, location), 1),
+						Origin: origin,
 					},
+					Function: function,
 				}
 				if bytes.HasPrefix(line[location.Column-1:], name) {
 					he.Name = string(match[reEscapesToHeap_Name])
@@ -173,14 +328,16 @@ func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 				res.Diagnostics = append(res.Diagnostics, he)
 
 				// Go versions prior to 1.20 seem to report column-1 for heap escapes
-				if bytes.HasPrefix(line[location.Column:], name) {
+				if columnKnown && location.Column < len(line) && bytes.HasPrefix(line[location.Column:], name) {
 					location.Column += 1
 					he := HeapEscape{
 						Diagnostic: Diagnostic{
-							Type:  DiagnosticHeapEscape,
-							Range: makeRange(locationToUnicode(sourceLines, location), 0),
+							Type:   DiagnosticHeapEscape,
+							Range:  makeRange(locationToUnicode(sourceLines, location), 0),
+							Origin: origin,
 						},
-						Name: string(match[reEscapesToHeap_Name]),
+						Name:     string(match[reEscapesToHeap_Name]),
+						Function: function,
 					}
 					res.Diagnostics = append(res.Diagnostics, he)
 				}
@@ -189,6 +346,36 @@ func parseBuildOutput(res *Result, sourceCode []byte, output io.Reader) {
 		}
 	}
 
+	if err := sc.Err(); err != nil {
+		res.Warnings = append(res.Warnings, Warning{
+			Source:  WarningSourceBuildOutput,
+			Line:    outputLine + 1,
+			Message: "read build output: " + err.Error(),
+		})
+	}
+
+	if len(functions) > 0 {
+		functions[len(functions)-1].AssemblyEnd = assemblyLine
+	}
+	var spills []Spill
+	res.Functions, spills = findFunctions(functions, headers, sourceLines)
+	for _, d := range spills {
+		res.Diagnostics = append(res.Diagnostics, d)
+	}
+	summarizeAllocations(res, functions)
+	attributeInlineCosts(res, sourceCode, sourceLines)
+	buildInlineTrees(res, functions)
+	res.Generics = findGenerics(functions, generics)
+	for _, d := range findDynamicCalls(functions, sourceLines) {
+		res.Diagnostics = append(res.Diagnostics, d)
+	}
+	for _, d := range findWriteBarriers(functions, sourceLines) {
+		res.Diagnostics = append(res.Diagnostics, d)
+	}
+	for _, d := range findStackChecks(functions, sourceLines) {
+		res.Diagnostics = append(res.Diagnostics, d)
+	}
+
 	res.Assembly = assembly.String()
 	res.BuildOutput = buildOutput.String()
 }
@@ -215,15 +402,32 @@ type bjsonPosition struct {
 }
 
 func parseJSON(res *Result, data []byte) {
+	if len(data) == 0 {
+		return
+	}
 	dec := json.NewDecoder(bytes.NewReader(data))
+	warn := func(record int, message string) {
+		res.Warnings = append(res.Warnings, Warning{
+			Source:  WarningSourceBuildJSON,
+			Line:    record,
+			Message: message,
+		})
+	}
 	var header bjsonHeader
 	if err := dec.Decode(&header); err != nil {
+		warn(1, "decode header: "+err.Error())
 		return
 	}
-	for dec.More() {
+	for record := 2; dec.More(); record++ {
 		var d bjsonDiagnostic
 		if err := dec.Decode(&d); err != nil {
-			continue
+			warn(record, "decode diagnostic: "+err.Error())
+			// Decoder can only recover from type mismatches.
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				continue
+			}
+			break
 		}
 		switch d.Code {
 		case "isInBounds", "isSliceInBounds":
@@ -276,16 +480,36 @@ func suffixWordLength(s []byte) int {
 	return blen
 }
 
+// guessColumn returns 1-based column and length of the last component of a dotted name
+// in line, or the first non-blank column. Used for positions reported without a column.
+func guessColumn(line, name []byte) (int, int) {
+	if i := bytes.LastIndexByte(name, '.'); i != -1 {
+		name = name[i+1:]
+	}
+	if pos := bytes.Index(line, name); pos != -1 && len(name) > 0 {
+		return pos + 1, len(name)
+	}
+	return len(line) - len(bytes.TrimLeft(line, " \t")) + 1, 0
+}
+
 func makeRange(start Location, length int) Range {
 	end := start
 	end.Column += length
 	return Range{Start: start, End: end}
 }
 
+// sourceLine returns 1-based line n of the source code.
+func sourceLine(sourceLines [][]byte, n int) ([]byte, bool) {
+	if n < 1 || n > len(sourceLines) {
+		return nil, false
+	}
+	return sourceLines[n-1], true
+}
+
 // locationToUnicode tranforms loc's Column to runes instead of bytes.
 func locationToUnicode(sourceLines [][]byte, loc Location) Location {
-	line := sourceLines[loc.Line-1]
-	if loc.Column > len(line) {
+	line, ok := sourceLine(sourceLines, loc.Line)
+	if !ok || loc.Column < 0 || loc.Column > len(line) {
 		return loc
 	}
 	line = line[:loc.Column]
@@ -301,12 +525,12 @@ func locationToUnicode(sourceLines [][]byte, loc Location) Location {
 	return Location{Line: loc.Line, Column: column}
 }
 
-func mustParseInt(s []byte) int {
+func parseInt(s []byte) (int, bool) {
 	i, err := strconv.Atoi(string(s))
 	if err != nil {
-		panic(err)
+		return 0, false
 	}
-	return i
+	return i, true
 }
 
 func bytesReplace(bs []byte, old, new byte) {
@@ -317,16 +541,24 @@ func bytesReplace(bs []byte, old, new byte) {
 	}
 }
 
-var reAssembly = regexp.MustCompile(`^\t(\w+) \d+ \(([^:]+):(\d+)\)\t(.*)`)
+// maxBuildOutputLineLength limits the length of a single line of compiler output.
+const maxBuildOutputLineLength = 1 << 20
+
+// Assembly position is either file:line, logical[physical] file:line pair
+// when affected by a //line directive or <unknown line number>.
+var reAssembly = regexp.MustCompile(`^\t(\w+) \d+ \((?:([^:\[\]]+):(\d+)(?:\[([^:\[\]]+):(\d+)\])?|<unknown line number>)\)\t(.*)`)
 
 const (
 	reAssembly_Address = iota + 1
 	reAssembly_File
 	reAssembly_Line
+	reAssembly_PhysFile
+	reAssembly_PhysLine
 	reAssembly_Code
 )
 
-var reBuildLine = regexp.MustCompile(`^(.+?):(\d+):(\d+): (.*)`)
+// Column is omitted for positions set by a //line directive without one.
+var reBuildLine = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?: (.*)`)
 
 const (
 	reBuildLine_FileName = iota + 1
@@ -335,14 +567,14 @@ const (
 	reBuildLine_Text
 )
 
-var reCanInline = regexp.MustCompile(`^can inline (\w+) with cost (\d+)`)
+var reCanInline = regexp.MustCompile(`^can inline (.+?) with cost (\d+)`)
 
 const (
 	reCanInline_Name = iota + 1
 	reCanInline_Cost
 )
 
-var reCannotInline = regexp.MustCompile(`^cannot inline (\w+): (.*)`)
+var reCannotInline = regexp.MustCompile(`^cannot inline (.+?): (.*)`)
 
 const (
 	reCannotInline_Name = iota + 1
@@ -355,8 +587,9 @@ const (
 	reInliningCall_Name = iota + 1
 )
 
-var reEscapesToHeap = regexp.MustCompile(`^(.+) escapes to heap( in .*)?:$`)
+var reEscapesToHeap = regexp.MustCompile(`^(.+) escapes to heap(?: in (.*))?:$`)
 
 const (
 	reEscapesToHeap_Name = iota + 1
+	reEscapesToHeap_Function
 )
@@ -1,9 +1,11 @@
 package parsers
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -58,3 +60,62 @@ func TestParseJSON(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func FuzzParseBuildOutput(f *testing.F) {
+	src, err := os.ReadFile("testdata/main.go")
+	if err != nil {
+		f.Fatal(err)
+	}
+	out, err := os.ReadFile("testdata/buildoutput")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(src, out)
+	f.Add(src, []byte("./main.go:99999:1: can inline main with cost 1 as: func() {}\n"))
+	f.Add(src, []byte("./main.go:30:99999: res escapes to heap:\n"))
+	f.Add(src, []byte("./main.go:0:0: inlining call to fmt.Println\n"))
+	f.Add(src, []byte("./main.go:99999999999999999999:1: inlining call to fmt.Println\n"))
+	f.Add(src, []byte("\t0x0000 00000 (./main.go:0)\tTEXT\tmain.main(SB), ABIInternal, $0-0\n"))
+	f.Add([]byte{}, []byte("./main.go:1:1: inlining call to f\n"))
+	f.Fuzz(func(t *testing.T, src, out []byte) {
+		res := &Result{}
+		parseBuildOutput(res, src, bytes.NewReader(out))
+		for _, m := range res.Mapping {
+			if m.AssemblyStart > m.AssemblyEnd {
+				t.Errorf("invalid mapping: %+v", m)
+			}
+		}
+	})
+}
+
+func FuzzParseJSON(f *testing.F) {
+	out, err := os.ReadFile("testdata/main.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(out)
+	f.Add([]byte(`{"version":0}` + "\n" + `{"code":"isInBounds","range":"oops"}` + "\n"))
+	f.Add([]byte(`{"version":0}` + "\n" + `{"code":`))
+	f.Add([]byte(`not json`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		res := &Result{}
+		parseJSON(res, data)
+	})
+}
+
+func TestParseInvalidPositions(t *testing.T) {
+	src := []byte("package main\n\nfunc main() {}\n")
+	out := strings.Join([]string{
+		"./main.go:42:6: can inline f with cost 2 as: func() {}",
+		"./main.go:3:99: inlining call to f",
+		"./main.go:3:6: can inline main with cost 0 as: func() {}",
+	}, "\n")
+	res := &Result{}
+	parseBuildOutput(res, src, strings.NewReader(out))
+	if len(res.Warnings) != 2 {
+		t.Errorf("expected 2 warnings, got %+v", res.Warnings)
+	}
+	if len(res.Diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic, got %+v", res.Diagnostics)
+	}
+}
@@ -0,0 +1,151 @@
+package parsers
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseDevirtualization parses static and PGO devirtualization messages, e.g.
+// "devirtualizing s.Area to Rect" or "PGO devirtualizing interface call s.Area to (*Circle).Area".
+func parseDevirtualization(text []byte) (Devirtualization, bool) {
+	if match := reDevirtualizing.FindSubmatch(text); match != nil {
+		return Devirtualization{
+			Diagnostic: Diagnostic{Type: DiagnosticDevirtualization},
+			Kind:       DevirtualizationStatic,
+			Call:       string(match[reDevirtualizing_Call]),
+			Target:     string(match[reDevirtualizing_Target]),
+		}, true
+	}
+	if match := rePGODevirtualizing.FindSubmatch(text); match != nil {
+		return Devirtualization{
+			Diagnostic: Diagnostic{Type: DiagnosticDevirtualization},
+			Kind:       DevirtualizationPGO,
+			Call:       string(match[rePGODevirtualizing_Call]),
+			Target:     string(match[rePGODevirtualizing_Target]),
+		}, true
+	}
+	return Devirtualization{}, false
+}
+
+// callRange returns the range of a call expression ending at location, which is
+// reported at the opening parenthesis of the call.
+func callRange(line []byte, location Location, call string) (Location, int) {
+	col := location.Column
+	if col > 0 && bytes.HasSuffix(line[:col-1], []byte(call)) {
+		return Location{Line: location.Line, Column: col - len(call)}, len(call)
+	}
+	if pos := bytes.Index(line, []byte(call)); pos != -1 && call != "" {
+		return Location{Line: location.Line, Column: pos + 1}, len(call)
+	}
+	return location, 1
+}
+
+// findDynamicCalls finds calls dispatched through interface method tables in the assembly.
+//
+// Interface method calls load the function pointer from the itab at a non-zero offset
+// right before an indirect call, while func value calls load it from the start of the closure.
+func findDynamicCalls(functions []asmFunction, sourceLines [][]byte) []Devirtualization {
+	var calls []Devirtualization
+	for _, fn := range functions {
+		lastLine := 0
+		for i, code := range fn.Code {
+			match := reIndirectCall.FindStringSubmatch(code)
+			if match == nil || fn.Lines[i] == 0 || fn.Lines[i] == lastLine {
+				continue
+			}
+			if !isMethodTableLoad(fn.Code[:i], match[reIndirectCall_Register]) {
+				continue
+			}
+			line, ok := sourceLine(sourceLines, fn.Lines[i])
+			if !ok {
+				continue
+			}
+			lastLine = fn.Lines[i]
+			calls = append(calls, dynamicCall(sourceLines, fn.Lines[i], line))
+		}
+	}
+	return calls
+}
+
+// isMethodTableLoad reports whether the last instruction writing the register
+// loads it from a non-zero offset, e.g. "MOVQ 24(DX), DX".
+func isMethodTableLoad(code []string, register string) bool {
+	for i := len(code) - 1; i >= 0; i-- {
+		match := reRegisterWrite.FindStringSubmatch(code[i])
+		if match == nil || match[reRegisterWrite_Destination] != register {
+			continue
+		}
+		op := match[reRegisterWrite_Op]
+		if strings.HasPrefix(op, "CMP") || strings.HasPrefix(op, "TEST") {
+			continue
+		}
+		load := reMemoryOperand.FindStringSubmatch(match[reRegisterWrite_Source])
+		if load == nil || !strings.HasPrefix(op, "MOV") {
+			return false
+		}
+		offset, err := strconv.Atoi(load[reMemoryOperand_Offset])
+		return err == nil && offset > 0
+	}
+	return false
+}
+
+// dynamicCall makes a diagnostic for the method call on the source line. The whole line
+// is marked when the call expression is ambiguous.
+func dynamicCall(sourceLines [][]byte, lineNumber int, line []byte) Devirtualization {
+	d := Devirtualization{
+		Diagnostic: Diagnostic{Type: DiagnosticDevirtualization},
+		Kind:       DevirtualizationDynamic,
+	}
+	if matches := reMethodCall.FindAllSubmatchIndex(line, -1); len(matches) == 1 {
+		start, end := matches[0][2*reMethodCall_Call], matches[0][2*reMethodCall_Call+1]
+		d.Call = string(line[start:end])
+		d.Range = makeRange(locationToUnicode(sourceLines, Location{Line: lineNumber, Column: start + 1}), end-start)
+		return d
+	}
+	indent := len(line) - len(bytes.TrimLeft(line, " \t"))
+	length := len(strings.TrimSpace(string(line)))
+	d.Range = makeRange(locationToUnicode(sourceLines, Location{Line: lineNumber, Column: indent + 1}), length)
+	return d
+}
+
+var reDevirtualizing = regexp.MustCompile(`^devirtualizing (.+) to (.+)$`)
+
+const (
+	reDevirtualizing_Call = iota + 1
+	reDevirtualizing_Target
+)
+
+var rePGODevirtualizing = regexp.MustCompile(`^PGO devirtualizing (?:\w+ call )?(.+) to (.+)$`)
+
+const (
+	rePGODevirtualizing_Call = iota + 1
+	rePGODevirtualizing_Target
+)
+
+var reIndirectCall = regexp.MustCompile(`^CALL \(?([A-Z][A-Z0-9]*)\)?$`)
+
+const (
+	reIndirectCall_Register = iota + 1
+)
+
+var reRegisterWrite = regexp.MustCompile(`^(\w+) (.+), ([A-Z][A-Z0-9]*)$`)
+
+const (
+	reRegisterWrite_Op = iota + 1
+	reRegisterWrite_Source
+	reRegisterWrite_Destination
+)
+
+var reMemoryOperand = regexp.MustCompile(`^(-?\d*)\(\w+\)$`)
+
+const (
+	reMemoryOperand_Offset = iota + 1
+)
+
+var reMethodCall = regexp.MustCompile(`(\w+(?:\.\w+)*\.\w+)\(`)
+
+const (
+	reMethodCall_Call = iota + 1
+)
@@ -0,0 +1,60 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDevirtualization(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"func total(shapes []Shape, f func() float64) (sum float64) {",
+		"\tfor _, s := range shapes {",
+		"\t\tsum += s.Area()",
+		"\t}",
+		"\treturn sum + f()",
+		"}",
+		"",
+		"func main() {",
+		"\tvar s Shape = Rect{W: 2, H: 3}",
+		"\tprintln(s.Area())",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"./main.go:12:16: devirtualizing s.Area to Rect",
+		"./main.go:5:16: PGO devirtualizing interface call s.Area to (*Circle).Area",
+		"\t0x0000 00000 (./main.go:3)\tTEXT\tmain.total(SB), ABIInternal, $40-24",
+		"\t0x002f 00047 (./main.go:5)\tMOVQ\t24(DX), DX",
+		"\t0x0033 00051 (./main.go:5)\tMOVQ\tCX, AX",
+		"\t0x0036 00054 (./main.go:5)\tCALL\tDX",
+		"\t0x0040 00064 (./main.go:7)\tMOVQ\t(DX), SI",
+		"\t0x0044 00068 (./main.go:7)\tCALL\tSI",
+		"\t0x0048 00072 (./main.go:7)\tRET",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	want := []Devirtualization{
+		{Kind: DevirtualizationStatic, Call: "s.Area", Target: "Rect", Diagnostic: Diagnostic{Range: Range{Location{12, 10}, Location{12, 16}}}},
+		{Kind: DevirtualizationPGO, Call: "s.Area", Target: "(*Circle).Area", Diagnostic: Diagnostic{Range: Range{Location{5, 10}, Location{5, 16}}}},
+		{Kind: DevirtualizationDynamic, Call: "s.Area", Diagnostic: Diagnostic{Range: Range{Location{5, 10}, Location{5, 16}}}},
+	}
+	var got []Devirtualization
+	for _, d := range res.Diagnostics {
+		if d, ok := d.(Devirtualization); ok {
+			got = append(got, d)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d devirtualization diagnostics, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		want[i].Type = DiagnosticDevirtualization
+		if got[i] != want[i] {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
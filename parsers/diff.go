@@ -0,0 +1,502 @@
+package parsers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ResultDiff is a semantic difference between two compilation results.
+type ResultDiff struct {
+	Functions   []FunctionDiff  `json:"functions"`
+	Diagnostics DiagnosticsDiff `json:"diagnostics"`
+}
+
+// FunctionDiff is a difference of assembly of a function present in either of the results.
+type FunctionDiff struct {
+	Name   string     `json:"name"`
+	Status DiffStatus `json:"status"`
+	Before *Function  `json:"before,omitempty"`
+	After  *Function  `json:"after,omitempty"`
+	// Aligned instructions of changed functions.
+	Lines []DiffLine `json:"lines,omitempty"`
+}
+
+type DiffStatus string
+
+const (
+	DiffUnchanged DiffStatus = "unchanged"
+	DiffChanged   DiffStatus = "changed"
+	DiffAdded     DiffStatus = "added"
+	DiffRemoved   DiffStatus = "removed"
+)
+
+// DiffLine is an assembly instruction present in either or both of the results.
+type DiffLine struct {
+	Kind DiffLineKind `json:"kind"`
+	// Assembly lines of the instruction, zero if not present.
+	Before int `json:"before,omitempty"`
+	After  int `json:"after,omitempty"`
+	// Normalized instruction text.
+	Text string `json:"text"`
+	// Instructions only differ in registers used.
+	Renamed bool `json:"renamed,omitempty"`
+}
+
+type DiffLineKind string
+
+const (
+	DiffLineEqual   DiffLineKind = "equal"
+	DiffLineRemoved DiffLineKind = "removed"
+	DiffLineAdded   DiffLineKind = "added"
+)
+
+// DiagnosticsDiff holds diagnostics present in only one of the results.
+type DiagnosticsDiff struct {
+	Added   []IDiagnostic `json:"added,omitempty"`
+	Removed []IDiagnostic `json:"removed,omitempty"`
+
+	NewEscapes     []string `json:"newEscapes,omitempty"`     // Values escaping to heap only after.
+	FixedEscapes   []string `json:"fixedEscapes,omitempty"`   // Values escaping to heap only before.
+	LostInlining   []string `json:"lostInlining,omitempty"`   // Functions no longer inlinable.
+	GainedInlining []string `json:"gainedInlining,omitempty"` // Functions inlinable only after.
+}
+
+// Diff compares assembly of functions and diagnostics of two compilation results.
+//
+// Functions are aligned by name. Instructions are compared ignoring addresses, branch targets,
+// compiler temporary numbering and register allocation, as well as PCDATA and FUNCDATA directives.
+// Diagnostics are compared by their source text, so that they match when unrelated lines of code change.
+func Diff(before, after Result, beforeSource, afterSource []byte) ResultDiff {
+	var res ResultDiff
+
+	beforeAsm := strings.Split(before.Assembly, "\n")
+	afterAsm := strings.Split(after.Assembly, "\n")
+	beforeByName := map[string]int{}
+	for i, fn := range before.Functions {
+		beforeByName[fn.Name] = i
+	}
+	seen := map[string]bool{}
+	for i := range after.Functions {
+		a := &after.Functions[i]
+		seen[a.Name] = true
+		j, ok := beforeByName[a.Name]
+		if !ok {
+			res.Functions = append(res.Functions, FunctionDiff{
+				Name:   a.Name,
+				Status: DiffAdded,
+				After:  a,
+				Lines:  diffInstructions(nil, functionInstructions(afterAsm, *a)),
+			})
+			continue
+		}
+		b := &before.Functions[j]
+		fd := FunctionDiff{Name: a.Name, Status: DiffUnchanged, Before: b, After: a}
+		lines := diffInstructions(functionInstructions(beforeAsm, *b), functionInstructions(afterAsm, *a))
+		for _, l := range lines {
+			if l.Kind != DiffLineEqual {
+				fd.Status, fd.Lines = DiffChanged, lines
+				break
+			}
+		}
+		res.Functions = append(res.Functions, fd)
+	}
+	for i := range before.Functions {
+		b := &before.Functions[i]
+		if seen[b.Name] {
+			continue
+		}
+		res.Functions = append(res.Functions, FunctionDiff{
+			Name:   b.Name,
+			Status: DiffRemoved,
+			Before: b,
+			Lines:  diffInstructions(functionInstructions(beforeAsm, *b), nil),
+		})
+	}
+
+	res.Diagnostics = diffDiagnostics(before.Diagnostics, after.Diagnostics, splitLines(beforeSource), splitLines(afterSource))
+	return res
+}
+
+// instruction is a normalized assembly instruction.
+type instruction struct {
+	Line int    // Assembly line.
+	Text string // Normalized text.
+	Key  string // Text with registers replaced.
+}
+
+func functionInstructions(asm []string, fn Function) []instruction {
+	var res []instruction
+	for line := fn.AssemblyStart; line <= fn.AssemblyEnd && line <= len(asm); line++ {
+		if line < 1 {
+			continue
+		}
+		text, ok := normalizeInstruction(asm[line-1])
+		if !ok {
+			continue
+		}
+		res = append(res, instruction{Line: line, Text: text, Key: instructionKey(text)})
+	}
+	return res
+}
+
+// normalizeInstruction strips the address and replaces branch targets and compiler temporary numbers,
+// e.g. "0x0004	JLS 100" becomes "JLS label".
+func normalizeInstruction(line string) (string, bool) {
+	if _, code, ok := strings.Cut(line, "\t"); ok {
+		line = code
+	}
+	op, operands, _ := strings.Cut(line, " ")
+	switch op {
+	case "", "PCDATA", "FUNCDATA":
+		return "", false
+	}
+	if (isBranch(op) || op == "JMP" || op == "B") && reBranchTarget.MatchString(operands) {
+		operands = reBranchTarget.ReplaceAllString(operands, "${1}label")
+	}
+	operands = reAutotmp.ReplaceAllString(operands, "autotmp")
+	if operands == "" {
+		return op, true
+	}
+	return op + " " + operands, true
+}
+
+// instructionKey replaces registers in operands of the instruction, so that instructions
+// differing only in register allocation match.
+func instructionKey(text string) string {
+	op, operands, ok := strings.Cut(text, " ")
+	if !ok {
+		return text
+	}
+	operands = reAsmRegister.ReplaceAllStringFunc(operands, func(s string) string {
+		match := reAsmRegister.FindStringSubmatch(s)
+		switch match[reAsmRegister_Name] {
+		case "SB", "SP", "FP", "PC":
+			return s
+		}
+		return match[reAsmRegister_Prefix] + "reg"
+	})
+	return op + " " + operands
+}
+
+// diffInstructions aligns instructions by the longest common subsequence of their keys.
+func diffInstructions(before, after []instruction) []DiffLine {
+	// The subsequence is found in linear space, but quadratic time.
+	const maxCells = 1 << 22
+
+	var lines []DiffLine
+	removed := func(in instruction) DiffLine {
+		return DiffLine{Kind: DiffLineRemoved, Before: in.Line, Text: in.Text}
+	}
+	added := func(in instruction) DiffLine {
+		return DiffLine{Kind: DiffLineAdded, After: in.Line, Text: in.Text}
+	}
+	if len(before)*len(after) > maxCells {
+		for _, in := range before {
+			lines = append(lines, removed(in))
+		}
+		for _, in := range after {
+			lines = append(lines, added(in))
+		}
+		return lines
+	}
+
+	// Keys are compared as integers.
+	ids := map[string]int{}
+	keys := func(ins []instruction) []int {
+		res := make([]int, len(ins))
+		for i, in := range ins {
+			id, ok := ids[in.Key]
+			if !ok {
+				id = len(ids)
+				ids[in.Key] = id
+			}
+			res[i] = id
+		}
+		return res
+	}
+	var matches [][2]int
+	commonSubsequence(keys(before), keys(after), 0, 0, &matches)
+
+	i, j := 0, 0
+	for _, match := range append(matches, [2]int{len(before), len(after)}) {
+		for ; i < match[0]; i++ {
+			lines = append(lines, removed(before[i]))
+		}
+		for ; j < match[1]; j++ {
+			lines = append(lines, added(after[j]))
+		}
+		if i == len(before) && j == len(after) {
+			break
+		}
+		lines = append(lines, DiffLine{
+			Kind:    DiffLineEqual,
+			Before:  before[i].Line,
+			After:   after[j].Line,
+			Text:    after[j].Text,
+			Renamed: before[i].Text != after[j].Text,
+		})
+		i++
+		j++
+	}
+	return lines
+}
+
+// commonSubsequence appends indices of matching elements of the longest common subsequence
+// of a and b, offset by ai and bi respectively, using Hirschberg's algorithm.
+func commonSubsequence(a, b []int, ai, bi int, matches *[][2]int) {
+	// Common prefix and suffix are matched as they are.
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		*matches = append(*matches, [2]int{ai, bi})
+		a, b, ai, bi = a[1:], b[1:], ai+1, bi+1
+	}
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	defer func(a, b []int) {
+		for k := range suffix {
+			*matches = append(*matches, [2]int{ai + len(a) + k, bi + len(b) + k})
+		}
+	}(a[:len(a)-suffix], b[:len(b)-suffix])
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return
+	case len(a) == 1:
+		if j := slices.Index(b, a[0]); j != -1 {
+			*matches = append(*matches, [2]int{ai, bi + j})
+		}
+		return
+	}
+
+	// Split b where the subsequences of the halves of a add up to the longest one.
+	mid := len(a) / 2
+	forward := lcsLengths(a[:mid], b, false)
+	backward := lcsLengths(a[mid:], b, true)
+	split := 0
+	for k := range forward {
+		if forward[k]+backward[k] > forward[split]+backward[split] {
+			split = k
+		}
+	}
+	commonSubsequence(a[:mid], b[:split], ai, bi, matches)
+	commonSubsequence(a[mid:], b[split:], ai+mid, bi+split, matches)
+}
+
+// lcsLengths returns lengths of the longest common subsequences of a and every prefix of b,
+// b[:k] for the k-th length, or of every suffix, b[k:], if reverse is set.
+func lcsLengths(a, b []int, reverse bool) []int {
+	at := func(s []int, i int) int {
+		if reverse {
+			return s[len(s)-1-i]
+		}
+		return s[i]
+	}
+	prev, curr := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if at(a, i) == at(b, j) {
+				curr[j+1] = prev[j] + 1
+			} else {
+				curr[j+1] = max(prev[j+1], curr[j])
+			}
+		}
+		prev, curr = curr, prev
+	}
+	if reverse {
+		slices.Reverse(prev)
+	}
+	return prev
+}
+
+func diffDiagnostics(before, after []IDiagnostic, beforeLines, afterLines [][]byte) DiagnosticsDiff {
+	var res DiagnosticsDiff
+
+	beforeKeys := make([]string, len(before))
+	remaining := map[string]int{}
+	for i, d := range before {
+		beforeKeys[i] = diagnosticKey(d, beforeLines)
+		remaining[beforeKeys[i]]++
+	}
+	for _, d := range after {
+		key := diagnosticKey(d, afterLines)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		res.Added = append(res.Added, d)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		if remaining[beforeKeys[i]] > 0 {
+			remaining[beforeKeys[i]]--
+			res.Removed = append(res.Removed, before[i])
+		}
+	}
+	for i, j := 0, len(res.Removed)-1; i < j; i, j = i+1, j-1 {
+		res.Removed[i], res.Removed[j] = res.Removed[j], res.Removed[i]
+	}
+
+	beforeEscapes, afterEscapes := escapingValues(before, beforeLines), escapingValues(after, afterLines)
+	res.NewEscapes = setDifference(afterEscapes, beforeEscapes)
+	res.FixedEscapes = setDifference(beforeEscapes, afterEscapes)
+	beforeInlinable, afterInlinable := inlinableFunctions(before), inlinableFunctions(after)
+	for name, could := range beforeInlinable {
+		if can, ok := afterInlinable[name]; ok && could && !can {
+			res.LostInlining = append(res.LostInlining, name)
+		}
+	}
+	for name, can := range afterInlinable {
+		if could, ok := beforeInlinable[name]; ok && !could && can {
+			res.GainedInlining = append(res.GainedInlining, name)
+		}
+	}
+	sort.Strings(res.LostInlining)
+	sort.Strings(res.GainedInlining)
+	return res
+}
+
+// diagnosticKey identifies a diagnostic by its fields and source text rather than position.
+func diagnosticKey(d IDiagnostic, lines [][]byte) string {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return ""
+	}
+	delete(fields, "range")
+	delete(fields, "origin")
+	if r, ok := diagnosticRange(d); ok {
+		fields["text"] = rangeText(lines, r)
+		if line, ok := sourceLine(lines, r.Start.Line); ok {
+			fields["line"] = string(bytes.TrimSpace(line))
+		}
+	}
+	buf, _ = json.Marshal(fields)
+	return string(buf)
+}
+
+func diagnosticRange(d IDiagnostic) (Range, bool) {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		return Range{}, false
+	}
+	var v struct {
+		Range Range `json:"range"`
+	}
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return Range{}, false
+	}
+	return v.Range, v.Range.Start.Line > 0
+}
+
+// rangeText returns source text of a single line range. Columns are in runes.
+func rangeText(lines [][]byte, r Range) string {
+	line, ok := sourceLine(lines, r.Start.Line)
+	if !ok {
+		return ""
+	}
+	runes := []rune(string(line))
+	start, end := r.Start.Column-1, r.End.Column-1
+	if r.End.Line != r.Start.Line {
+		end = len(runes)
+	}
+	if start < 0 || start > len(runes) || end < start {
+		return ""
+	}
+	return string(runes[start:min(end, len(runes))])
+}
+
+// escapingValues returns values escaping to heap along with their source lines.
+func escapingValues(diagnostics []IDiagnostic, lines [][]byte) map[string]bool {
+	values := map[string]bool{}
+	for _, d := range diagnostics {
+		he, ok := d.(HeapEscape)
+		if !ok {
+			continue
+		}
+		name := he.Name
+		if name == "" {
+			if match := reEscapesToHeap.FindStringSubmatch(he.Message); match != nil {
+				name = match[reEscapesToHeap_Name]
+			}
+		}
+		line, _ := sourceLine(lines, he.Range.Start.Line)
+		values[name+" in "+string(bytes.TrimSpace(line))] = true
+	}
+	return values
+}
+
+// inlinableFunctions returns whether functions analyzed for inlining can be inlined.
+func inlinableFunctions(diagnostics []IDiagnostic) map[string]bool {
+	functions := map[string]bool{}
+	for _, d := range diagnostics {
+		if ia, ok := d.(InliningAnalysis); ok {
+			functions[ia.Name] = functions[ia.Name] || ia.CanInline
+		}
+	}
+	return functions
+}
+
+func setDifference(a, b map[string]bool) []string {
+	var res []string
+	for k := range a {
+		if !b[k] {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func splitLines(source []byte) [][]byte {
+	return bytes.Split(source, []byte("\n"))
+}
+
+// Fingerprint returns hashes of normalized assembly and of diagnostics of the result. Results
+// differing only in addresses, branch targets or positions of diagnostics have equal fingerprints.
+func Fingerprint(res Result, source []byte) (string, string) {
+	asm := sha256.New()
+	for _, line := range strings.Split(res.Assembly, "\n") {
+		if text, ok := normalizeInstruction(line); ok {
+			_, _ = io.WriteString(asm, text+"\n")
+		}
+	}
+
+	lines := splitLines(source)
+	keys := make([]string, 0, len(res.Diagnostics))
+	for _, d := range res.Diagnostics {
+		keys = append(keys, diagnosticKey(d, lines))
+	}
+	sort.Strings(keys)
+	diag := sha256.New()
+	for _, key := range keys {
+		_, _ = io.WriteString(diag, key+"\n")
+	}
+
+	return hex.EncodeToString(asm.Sum(nil)), hex.EncodeToString(diag.Sum(nil))
+}
+
+var reAsmRegister = regexp.MustCompile(`(^|[ ,(\[*])([A-Z][A-Z0-9]{0,3})\b`)
+
+const (
+	reAsmRegister_Prefix = iota + 1
+	reAsmRegister_Name
+)
+
+var reBranchTarget = regexp.MustCompile(`(^|, )\d+$`)
+
+const (
+	reBranchTarget_Prefix = iota + 1
+)
+
+var reAutotmp = regexp.MustCompile(`autotmp_\d+`)
@@ -0,0 +1,185 @@
+package parsers
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	beforeSource := strings.Join([]string{
+		"package main",
+		"",
+		"func square(x int) int {",
+		"\treturn x * x",
+		"}",
+		"",
+		"func main() {",
+		"\tprintln(square(2))",
+		"}",
+	}, "\n")
+	afterSource := strings.Join([]string{
+		"package main",
+		"",
+		"// square returns x squared.",
+		"func square(x int) int {",
+		"\treturn x * x",
+		"}",
+		"",
+		"func main() {",
+		"\tprintln(square(2))",
+		"}",
+	}, "\n")
+
+	before := Result{
+		Assembly: strings.Join([]string{
+			"0x0000\tTEXT main.square(SB), NOSPLIT|ABIInternal, $0-8",
+			"0x0000\tFUNCDATA $0, gclocals·g2BeySu+wFnoycgXfElmcg==(SB)",
+			"0x0000\tIMULQ AX, AX",
+			"0x0004\tRET",
+			"0x0000\tTEXT main.main(SB), ABIInternal, $16-0",
+			"0x0000\tCMPQ SP, 16(R14)",
+			"0x0004\tJLS 40",
+			"0x0006\tMOVL $4, AX",
+			"0x000b\tCALL runtime.printint(SB)",
+			"0x0010\tRET",
+		}, "\n"),
+		Functions: []Function{
+			{Name: "main.square", AssemblyStart: 1, AssemblyEnd: 4},
+			{Name: "main.main", AssemblyStart: 5, AssemblyEnd: 10},
+		},
+		Diagnostics: []IDiagnostic{
+			InliningAnalysis{
+				Diagnostic: Diagnostic{Type: DiagnosticInliningAnalysis, Range: Range{Location{3, 6}, Location{3, 12}}},
+				Name:       "square",
+				CanInline:  true,
+				Cost:       4,
+			},
+		},
+	}
+	after := Result{
+		Assembly: strings.Join([]string{
+			"0x0000\tTEXT main.square(SB), NOSPLIT|ABIInternal, $0-8",
+			"0x0000\tIMULQ BX, BX",
+			"0x0004\tRET",
+			"0x0000\tTEXT main.main(SB), ABIInternal, $16-0",
+			"0x0000\tCMPQ SP, 16(R14)",
+			"0x0004\tJLS 44",
+			"0x0006\tMOVL $4, AX",
+			"0x000b\tCALL runtime.printlock(SB)",
+			"0x0010\tCALL runtime.printint(SB)",
+			"0x0015\tRET",
+			"0x0000\tTEXT main.init(SB), ABIInternal, $0-0",
+			"0x0000\tRET",
+		}, "\n"),
+		Functions: []Function{
+			{Name: "main.square", AssemblyStart: 1, AssemblyEnd: 3},
+			{Name: "main.main", AssemblyStart: 4, AssemblyEnd: 10},
+			{Name: "main.init", AssemblyStart: 11, AssemblyEnd: 12},
+		},
+		Diagnostics: []IDiagnostic{
+			InliningAnalysis{
+				Diagnostic: Diagnostic{Type: DiagnosticInliningAnalysis, Range: Range{Location{4, 6}, Location{4, 12}}},
+				Name:       "square",
+				Reason:     "marked go:noinline",
+			},
+			HeapEscape{
+				Diagnostic: Diagnostic{Type: DiagnosticHeapEscape, Range: Range{Location{9, 17}, Location{9, 18}}},
+				Name:       "2",
+			},
+		},
+	}
+
+	diff := Diff(before, after, []byte(beforeSource), []byte(afterSource))
+
+	if len(diff.Functions) != 3 {
+		t.Fatalf("expected 3 functions, got %d", len(diff.Functions))
+	}
+	if fd := diff.Functions[0]; fd.Name != "main.square" || fd.Status != DiffUnchanged || fd.Lines != nil {
+		t.Errorf("expected unchanged square, got %+v", fd)
+	}
+	wantMain := []DiffLine{
+		{Kind: DiffLineEqual, Before: 5, After: 4, Text: "TEXT main.main(SB), ABIInternal, $16-0"},
+		{Kind: DiffLineEqual, Before: 6, After: 5, Text: "CMPQ SP, 16(R14)"},
+		{Kind: DiffLineEqual, Before: 7, After: 6, Text: "JLS label"},
+		{Kind: DiffLineEqual, Before: 8, After: 7, Text: "MOVL $4, AX"},
+		{Kind: DiffLineAdded, After: 8, Text: "CALL runtime.printlock(SB)"},
+		{Kind: DiffLineEqual, Before: 9, After: 9, Text: "CALL runtime.printint(SB)"},
+		{Kind: DiffLineEqual, Before: 10, After: 10, Text: "RET"},
+	}
+	if fd := diff.Functions[1]; fd.Status != DiffChanged || !reflect.DeepEqual(fd.Lines, wantMain) {
+		t.Errorf("expected changed main %+v, got %+v", wantMain, fd)
+	}
+	if fd := diff.Functions[2]; fd.Name != "main.init" || fd.Status != DiffAdded || len(fd.Lines) != 2 {
+		t.Errorf("expected added init, got %+v", fd)
+	}
+
+	if len(diff.Diagnostics.Added) != 2 || len(diff.Diagnostics.Removed) != 1 {
+		t.Errorf("expected 2 added and 1 removed diagnostics, got %+v", diff.Diagnostics)
+	}
+	if want := []string{"2 in println(square(2))"}; !reflect.DeepEqual(diff.Diagnostics.NewEscapes, want) {
+		t.Errorf("expected new escapes %q, got %q", want, diff.Diagnostics.NewEscapes)
+	}
+	if want := []string{"square"}; !reflect.DeepEqual(diff.Diagnostics.LostInlining, want) {
+		t.Errorf("expected lost inlining %q, got %q", want, diff.Diagnostics.LostInlining)
+	}
+}
+
+func TestInstructionKey(t *testing.T) {
+	tests := []struct {
+		before, after string
+		equal         bool
+	}{
+		{"IMULQ AX, AX", "IMULQ BX, BX", true},
+		{"MOVQ 8(R8)(R9*1), R10", "MOVQ 8(DX)(CX*1), SI", true},
+		{"MOVD R1, main.st-32(SP)", "MOVD R2, main.st-32(SP)", true},
+		{"MOVQ $100, main.s+8(SB)", "MOVQ $100, main.s+16(SB)", false},
+		{"ADDQ $1, AX", "SUBQ $1, AX", false},
+		{"CALL main.F(SB)", "CALL main.G(SB)", false},
+	}
+	for _, tt := range tests {
+		if got := instructionKey(tt.before) == instructionKey(tt.after); got != tt.equal {
+			t.Errorf("instructionKey(%q) == instructionKey(%q) is %v, want %v", tt.before, tt.after, got, tt.equal)
+		}
+	}
+}
+
+func TestCommonSubsequence(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for range 200 {
+		a, b := make([]int, rnd.IntN(30)), make([]int, rnd.IntN(30))
+		for i := range a {
+			a[i] = rnd.IntN(4)
+		}
+		for i := range b {
+			b[i] = rnd.IntN(4)
+		}
+
+		// Length of the longest common subsequence by the quadratic table.
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		var matches [][2]int
+		commonSubsequence(a, b, 0, 0, &matches)
+		if len(matches) != lcs[0][0] {
+			t.Fatalf("commonSubsequence(%v, %v) has %d matches, want %d", a, b, len(matches), lcs[0][0])
+		}
+		for k, m := range matches {
+			if a[m[0]] != b[m[1]] || (k > 0 && (m[0] <= matches[k-1][0] || m[1] <= matches[k-1][1])) {
+				t.Fatalf("commonSubsequence(%v, %v) = %v is not a common subsequence", a, b, matches)
+			}
+		}
+	}
+}
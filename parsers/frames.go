@@ -0,0 +1,346 @@
+package parsers
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Function is a function found in the assembly output.
+type Function struct {
+	Name          string `json:"name"`
+	SourceLine    int    `json:"source,omitempty"`
+	AssemblyStart int    `json:"start"`
+	AssemblyEnd   int    `json:"end"`
+
+	Size   int `json:"size"`   // Code size in bytes.
+	Frame  int `json:"frame"`  // Stack frame size in bytes.
+	Args   int `json:"args"`   // Size of arguments and results in bytes.
+	Locals int `json:"locals"` // Size of locals in bytes.
+
+	NoSplit    bool        `json:"nosplit"`
+	StackSplit *StackSplit `json:"stackSplit,omitempty"`
+
+	Spills  int `json:"spills"`
+	Reloads int `json:"reloads"`
+
+	Allocations Allocations `json:"allocations"`
+	// Calls inlined into the function.
+	InlineTree []InlineNode `json:"inlineTree,omitempty"`
+}
+
+// StackSplit holds assembly lines of the stack bound check in the function prologue
+// and of the morestack call growing the stack when the check fails.
+type StackSplit struct {
+	PrologueStart  int `json:"prologueStart"`
+	PrologueEnd    int `json:"prologueEnd"`
+	MorestackStart int `json:"morestackStart"`
+	MorestackEnd   int `json:"morestackEnd"`
+}
+
+// stextHeader is a function symbol header preceding the assembly, e.g.
+// "main.fibonacci STEXT size=89 args=0x8 locals=0x18 funcid=0x0 align=0x0".
+type stextHeader struct {
+	Size    int
+	Args    int
+	Locals  int
+	NoSplit bool
+}
+
+func parseSTEXTHeader(line []byte) (string, stextHeader, bool) {
+	match := reSTEXTHeader.FindSubmatch(line)
+	if match == nil {
+		return "", stextHeader{}, false
+	}
+	var h stextHeader
+	for _, field := range strings.Fields(string(match[reSTEXTHeader_Fields])) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			h.NoSplit = h.NoSplit || key == "nosplit"
+			continue
+		}
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "size":
+			h.Size = int(n)
+		case "args":
+			h.Args = int(n)
+		case "locals":
+			h.Locals = int(n)
+		}
+	}
+	return string(match[reSTEXTHeader_Name]), h, true
+}
+
+// parseFrameSize parses frame and argument sizes of a TEXT directive, e.g. "$24-8".
+func parseFrameSize(text string) (int, int, bool) {
+	match := reFrameSize.FindStringSubmatch(text)
+	if match == nil {
+		return 0, 0, false
+	}
+	frame, _ := strconv.Atoi(match[reFrameSize_Frame])
+	args, _ := strconv.Atoi(match[reFrameSize_Args])
+	return frame, args, true
+}
+
+// findFunctions describes stack frames of functions and finds register spills around calls.
+func findFunctions(functions []asmFunction, headers map[string]stextHeader, sourceLines [][]byte) ([]Function, []Spill) {
+	var res []Function
+	var spills []Spill
+	for _, fn := range functions {
+		f := Function{
+			Name:          fn.Symbol,
+			SourceLine:    fn.SourceLine,
+			AssemblyStart: fn.AssemblyStart,
+			AssemblyEnd:   fn.AssemblyEnd,
+			Frame:         fn.Frame,
+			Args:          fn.Args,
+			NoSplit:       strings.Contains(fn.Flags, "NOSPLIT"),
+		}
+		if h, ok := headers[fn.Symbol]; ok {
+			f.Size, f.Args, f.Locals = h.Size, h.Args, h.Locals
+			f.NoSplit = f.NoSplit || h.NoSplit
+		}
+
+		var skipStart, skipEnd int
+		if split, ok := findStackSplit(fn); ok {
+			f.StackSplit = &split
+			skipStart, skipEnd = split.MorestackStart-fn.AssemblyStart, split.MorestackEnd-fn.AssemblyStart
+		}
+
+		for _, s := range findSpills(fn, skipStart, skipEnd) {
+			f.Spills++
+			f.Reloads += s.Reloads
+			line, ok := sourceLine(sourceLines, s.Line)
+			if !ok {
+				continue
+			}
+			spills = append(spills, makeSpill(sourceLines, line, fn.Symbol, s))
+		}
+
+		res = append(res, f)
+	}
+	return res, spills
+}
+
+// findStackSplit finds stack bound check prologue ending with a conditional branch
+// to the morestack call, and the morestack call itself, which jumps back to the function start.
+func findStackSplit(fn asmFunction) (StackSplit, bool) {
+	call := -1
+	for i, code := range fn.Code {
+		if strings.HasPrefix(code, "CALL runtime.morestack") {
+			call = i
+			break
+		}
+	}
+	if call == -1 {
+		return StackSplit{}, false
+	}
+
+	branch := -1
+	for i := 1; i < call; i++ {
+		if op, _, _ := strings.Cut(fn.Code[i], " "); isBranch(op) {
+			branch = i
+			break
+		}
+	}
+	if branch == -1 {
+		return StackSplit{}, false
+	}
+
+	start := call
+	if _, target, ok := strings.Cut(fn.Code[branch], " "); ok {
+		if pc, err := strconv.Atoi(target); err == nil {
+			for i := branch + 1; i < call; i++ {
+				if fn.Addresses[i] == pc {
+					start = i
+					break
+				}
+			}
+		}
+	}
+	end := call
+	for i := call + 1; i < len(fn.Code); i++ {
+		end = i
+		if strings.HasPrefix(fn.Code[i], "JMP ") {
+			break
+		}
+	}
+
+	return StackSplit{
+		PrologueStart:  fn.AssemblyStart + 1,
+		PrologueEnd:    fn.AssemblyStart + branch,
+		MorestackStart: fn.AssemblyStart + start,
+		MorestackEnd:   fn.AssemblyStart + end,
+	}, true
+}
+
+// isBranch reports whether op is a conditional branch, e.g. JLS on amd64 or BLS on arm64.
+func isBranch(op string) bool {
+	switch {
+	case op == "JMP" || op == "BL":
+		return false
+	case strings.HasPrefix(op, "J"):
+		return true
+	case strings.HasPrefix(op, "B") && !strings.HasPrefix(op, "BIC") && !strings.HasPrefix(op, "BFI"):
+		return true
+	}
+	return false
+}
+
+// spill is a register value stored to a stack slot and reloaded after a call.
+type spill struct {
+	Name    string
+	Line    int // Source line of the call.
+	Call    string
+	Reloads int
+}
+
+// findSpills finds registers stored to named stack slots and loaded back
+// after a call, skipping instructions in [skipStart, skipEnd].
+func findSpills(fn asmFunction, skipStart, skipEnd int) []spill {
+	type slotState struct {
+		call     string // First call after the store.
+		callLine int
+		reloads  int
+	}
+	slots := map[string]*slotState{}
+	var order []string
+	var spills []spill
+
+	flush := func(slot string) {
+		if s := slots[slot]; s != nil && s.reloads > 0 {
+			spills = append(spills, spill{
+				Name:    strings.TrimPrefix(slot, "main."),
+				Line:    s.callLine,
+				Call:    s.call,
+				Reloads: s.reloads,
+			})
+		}
+		delete(slots, slot)
+	}
+
+	for i, code := range fn.Code {
+		if skipEnd > 0 && i >= skipStart && i <= skipEnd {
+			continue
+		}
+		if target, ok := strings.CutPrefix(code, "CALL "); ok {
+			for _, s := range slots {
+				if s.call == "" {
+					s.call, s.callLine = strings.TrimSuffix(target, "(SB)"), fn.Lines[i]
+				}
+			}
+			continue
+		}
+		match := reMove.FindStringSubmatch(code)
+		if match == nil {
+			continue
+		}
+		src, dst := match[reMove_Source], match[reMove_Destination]
+		if slot, ok := stackSlot(dst); ok && reRegister.MatchString(src) {
+			if _, exists := slots[slot]; exists {
+				flush(slot)
+			} else {
+				order = append(order, slot)
+			}
+			slots[slot] = &slotState{}
+			continue
+		}
+		if slot, ok := stackSlot(src); ok && reRegister.MatchString(dst) {
+			if s := slots[slot]; s != nil && s.call != "" {
+				s.reloads++
+			}
+		}
+	}
+	for _, slot := range order {
+		flush(slot)
+	}
+	return spills
+}
+
+// stackSlot returns the name of a named stack slot operand, e.g. "main.n" for "main.n+32(SP)".
+func stackSlot(operand string) (string, bool) {
+	match := reStackSlot.FindStringSubmatch(operand)
+	if match == nil {
+		return "", false
+	}
+	return match[reStackSlot_Name], true
+}
+
+func makeSpill(sourceLines [][]byte, line []byte, function string, s spill) Spill {
+	d := Spill{
+		Diagnostic: Diagnostic{Type: DiagnosticSpill},
+		Name:       s.Name,
+		Function:   function,
+		Call:       s.Call,
+		Reloads:    s.Reloads,
+	}
+	// Mark the call if it is present in the source, or the whole line otherwise.
+	start := Location{Line: s.Line, Column: len(line) - len(bytes.TrimLeft(line, " \t")) + 1}
+	length := len(bytes.TrimSpace(line))
+	callee := baseFunctionName(s.Call)
+	if i := strings.LastIndexByte(callee, '.'); i != -1 {
+		callee = callee[i+1:]
+	}
+	if i := indexCall(line, callee); i != -1 {
+		start.Column, length = i+1, len(callee)
+	}
+	d.Range = makeRange(locationToUnicode(sourceLines, start), length)
+	return d
+}
+
+// indexCall returns the index of the first call to the function in the line, or -1 if there is none.
+func indexCall(line []byte, callee string) int {
+	if callee == "" {
+		return -1
+	}
+	call := []byte(callee + "(")
+	for offset := 0; ; {
+		i := bytes.Index(line[offset:], call)
+		if i == -1 {
+			return -1
+		}
+		i += offset
+		if i == 0 || !isIdentByte(line[i-1]) {
+			return i
+		}
+		offset = i + 1
+	}
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+var reSTEXTHeader = regexp.MustCompile(`^(.+?) STEXT((?: \S+)*)$`)
+
+const (
+	reSTEXTHeader_Name = iota + 1
+	reSTEXTHeader_Fields
+)
+
+var reFrameSize = regexp.MustCompile(`\$(-?\d+)(?:-(\d+))?$`)
+
+const (
+	reFrameSize_Frame = iota + 1
+	reFrameSize_Args
+)
+
+var reMove = regexp.MustCompile(`^F?MOV\w* (\S+), (\S+)$`)
+
+const (
+	reMove_Source = iota + 1
+	reMove_Destination
+)
+
+var reStackSlot = regexp.MustCompile(`^(main\.[^()]+?)(?:[+-]\d+)?\((?:SP|FP)\)$`)
+
+const (
+	reStackSlot_Name = iota + 1
+)
+
+var reRegister = regexp.MustCompile(`^[A-Z][A-Z0-9]*$`)
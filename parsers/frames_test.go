@@ -0,0 +1,144 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSTEXTHeader(t *testing.T) {
+	tests := []struct {
+		line   string
+		name   string
+		header stextHeader
+	}{
+		{
+			"main.fibonacci STEXT size=89 args=0x8 locals=0x18 funcid=0x0 align=0x0",
+			"main.fibonacci", stextHeader{Size: 89, Args: 8, Locals: 24},
+		},
+		{
+			"main.square STEXT nosplit size=5 align=0x0 args=0x8 locals=0x0 funcid=0x0",
+			"main.square", stextHeader{Size: 5, Args: 8, NoSplit: true},
+		},
+		{
+			"main.Keys[go.shape.struct { a int }] STEXT dupok size=16 args=0x18 locals=0x0 funcid=0x0 align=0x0",
+			"main.Keys[go.shape.struct { a int }]", stextHeader{Size: 16, Args: 24},
+		},
+	}
+	for _, tt := range tests {
+		name, header, ok := parseSTEXTHeader([]byte(tt.line))
+		if !ok || name != tt.name || header != tt.header {
+			t.Errorf("parseSTEXTHeader(%q) = %q %+v %v, want %q %+v", tt.line, name, header, ok, tt.name, tt.header)
+		}
+	}
+}
+
+func TestIndexCall(t *testing.T) {
+	tests := []struct {
+		line   string
+		callee string
+		index  int
+	}{
+		{"\tx := f(1)", "f", 6},
+		{"\tx := ff(1) + f(2)", "f", 14},
+		{"\tx := obj.f(1)", "f", 10},
+		{"\tx := f_(1)", "f", -1},
+		{"\tx := f", "f", -1},
+		{"\tx := f(1)", "", -1},
+	}
+	for _, tt := range tests {
+		if i := indexCall([]byte(tt.line), tt.callee); i != tt.index {
+			t.Errorf("indexCall(%q, %q) = %d, want %d", tt.line, tt.callee, i, tt.index)
+		}
+	}
+}
+
+func TestParseStackFrames(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"func fibonacci(n int) int {",
+		"\tif n <= 1 {",
+		"\t\treturn n",
+		"\t}",
+		"\treturn fibonacci(n-1) + fibonacci(n-2)",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"main.fibonacci STEXT size=89 args=0x8 locals=0x18 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:3)\tTEXT\tmain.fibonacci(SB), ABIInternal, $24-8",
+		"\t0x0000 00000 (./main.go:3)\tCMPQ\tSP, 16(R14)",
+		"\t0x0004 00004 (./main.go:3)\tPCDATA\t$0, $-2",
+		"\t0x0004 00004 (./main.go:3)\tJLS\t72",
+		"\t0x0006 00006 (./main.go:3)\tPUSHQ\tBP",
+		"\t0x000e 00014 (./main.go:4)\tCMPQ\tAX, $1",
+		"\t0x0012 00018 (./main.go:4)\tJGT\t26",
+		"\t0x0019 00025 (./main.go:5)\tRET",
+		"\t0x001a 00026 (./main.go:4)\tMOVQ\tAX, main.n+32(SP)",
+		"\t0x001f 00031 (./main.go:7)\tDECQ\tAX",
+		"\t0x0022 00034 (./main.go:7)\tCALL\tmain.fibonacci(SB)",
+		"\t0x0027 00039 (./main.go:7)\tMOVQ\tAX, main..autotmp_4+8(SP)",
+		"\t0x002c 00044 (./main.go:7)\tMOVQ\tmain.n+32(SP), AX",
+		"\t0x0031 00049 (./main.go:7)\tADDQ\t$-2, AX",
+		"\t0x0035 00053 (./main.go:7)\tCALL\tmain.fibonacci(SB)",
+		"\t0x003a 00058 (./main.go:7)\tMOVQ\tmain..autotmp_4+8(SP), CX",
+		"\t0x0047 00071 (./main.go:7)\tRET",
+		"\t0x0048 00072 (./main.go:7)\tNOP",
+		"\t0x0048 00072 (./main.go:3)\tMOVQ\tAX, 8(SP)",
+		"\t0x004d 00077 (./main.go:3)\tCALL\truntime.morestack_noctxt(SB)",
+		"\t0x0052 00082 (./main.go:3)\tMOVQ\t8(SP), AX",
+		"\t0x0057 00087 (./main.go:3)\tJMP\t0",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	if len(res.Functions) != 1 {
+		t.Fatalf("expected 1 function, got %d", len(res.Functions))
+	}
+	want := Function{
+		Name:          "main.fibonacci",
+		SourceLine:    3,
+		AssemblyStart: 1,
+		AssemblyEnd:   22,
+		Size:          89,
+		Frame:         24,
+		Args:          8,
+		Locals:        24,
+		StackSplit: &StackSplit{
+			PrologueStart:  2,
+			PrologueEnd:    4,
+			MorestackStart: 18,
+			MorestackEnd:   22,
+		},
+		Spills:  2,
+		Reloads: 2,
+	}
+	got := res.Functions[0]
+	if got.StackSplit == nil || *got.StackSplit != *want.StackSplit {
+		t.Errorf("expected stack split %+v, got %+v", want.StackSplit, got.StackSplit)
+	}
+	got.StackSplit = want.StackSplit
+	if !got.Allocations.ZeroAlloc || len(got.Allocations.Sites) != 0 {
+		t.Errorf("expected no allocations, got %+v", got.Allocations)
+	}
+	got.Allocations = want.Allocations
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected function %+v, got %+v", want, got)
+	}
+
+	var spills []Spill
+	for _, d := range res.Diagnostics {
+		if d, ok := d.(Spill); ok {
+			spills = append(spills, d)
+		}
+	}
+	if len(spills) != 2 || spills[0].Name != "n" || spills[1].Name != ".autotmp_4" {
+		t.Fatalf("unexpected spills: %+v", spills)
+	}
+	wantRange := Range{Location{7, 9}, Location{7, 18}}
+	if spills[0].Range != wantRange || spills[0].Call != "main.fibonacci" {
+		t.Errorf("expected spill across main.fibonacci at %+v, got %+v", wantRange, spills[0])
+	}
+}
@@ -0,0 +1,317 @@
+package parsers
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GenericFunction groups instantiations of a generic function or method.
+type GenericFunction struct {
+	Name           string          `json:"name"`
+	SourceLine     int             `json:"source"`
+	Instantiations []Instantiation `json:"instantiations"`
+}
+
+// Instantiation is a compiled instantiation of a generic function.
+//
+// Shape instantiations are stenciled once per GC shape of type arguments and take a dictionary
+// as a hidden first argument. Instantiations with concrete type arguments are wrappers calling
+// a shape instantiation with the dictionary for their type arguments.
+type Instantiation struct {
+	Name     string   `json:"name"`
+	TypeArgs []string `json:"typeArgs"`
+	Shape    bool     `json:"shape"`
+
+	// Shape instantiation called by the wrapper.
+	ShapeName string `json:"shapeName,omitempty"`
+	// Dictionaries passed to the shape instantiation, or by the wrapper.
+	Dictionaries []Dictionary `json:"dictionaries,omitempty"`
+	// Number of dictionary entries of the shape instantiation, if known.
+	DictionaryEntries int `json:"dictionaryEntries,omitempty"`
+
+	AssemblyStart int `json:"start"`
+	AssemblyEnd   int `json:"end"`
+}
+
+// Dictionary holds type information passed to shape instantiations,
+// e.g. "Map[int,string]" for main..dict.Map[int,string].
+type Dictionary struct {
+	Name string `json:"name"`
+	Size int    `json:"size,omitempty"`
+}
+
+// asmFunction is a function found in the assembly output.
+type asmFunction struct {
+	Symbol        string
+	Flags         string
+	SourceLine    int
+	AssemblyStart int
+	AssemblyEnd   int
+	Frame         int
+	Args          int
+	Code          []string
+	Lines         []int // Physical source line of each instruction, zero if unknown.
+	Addresses     []int // Program counter of each instruction.
+}
+
+// parseTextDirective parses assembly TEXT directive, e.g.
+// "TEXT main.Map[go.shape.int](SB), DUPOK|ABIInternal, $112-40".
+func parseTextDirective(code string) (asmFunction, bool) {
+	rest, ok := strings.CutPrefix(code, "TEXT ")
+	if !ok {
+		return asmFunction{}, false
+	}
+	i := strings.LastIndex(rest, "(SB)")
+	if i == -1 {
+		return asmFunction{}, false
+	}
+	fn := asmFunction{Symbol: rest[:i]}
+	fn.Frame, fn.Args, _ = parseFrameSize(rest)
+	if flags, _, ok := strings.Cut(strings.TrimPrefix(rest[i+len("(SB)"):], ", "), ", "); ok {
+		fn.Flags = flags
+	}
+	return fn, true
+}
+
+// genericsInfo is collected from inlining analysis, keyed by function name,
+// and from dictionary symbols, keyed by dictionary name.
+type genericsInfo struct {
+	dictionaryEntries map[string]int
+	wrapperCalls      map[string]dictionaryCall
+	dictionarySizes   map[string]int
+}
+
+// findGenerics groups instantiations of generic functions found in the assembly.
+func findGenerics(functions []asmFunction, info genericsInfo) []GenericFunction {
+	var generics []GenericFunction
+	genericByName := map[string]int{}
+	shapeDictionaries := map[string][]Dictionary{}
+	dictionary := func(name string) Dictionary {
+		return Dictionary{Name: name, Size: info.dictionarySizes[name]}
+	}
+
+	for _, fn := range functions {
+		name, ok := strings.CutPrefix(fn.Symbol, "main.")
+		if !ok {
+			continue
+		}
+		baseName, typeArgs, ok := splitInstantiation(name)
+		if !ok {
+			continue
+		}
+		inst := Instantiation{
+			Name:              name,
+			TypeArgs:          typeArgs,
+			Shape:             isShapeInstantiation(typeArgs),
+			DictionaryEntries: info.dictionaryEntries[name],
+			AssemblyStart:     fn.AssemblyStart,
+			AssemblyEnd:       fn.AssemblyEnd,
+		}
+		if !inst.Shape {
+			// Wrappers usually have the shape instantiation inlined.
+			call, ok := info.wrapperCalls[name]
+			if calls := dictionaryCalls(fn.Code); !ok && len(calls) > 0 {
+				call, ok = calls[0], true
+			}
+			if ok {
+				inst.ShapeName = call.Shape
+				inst.Dictionaries = []Dictionary{dictionary(call.Dictionary)}
+			} else if dict := dictionaryName(name); info.dictionarySizes[dict] != 0 {
+				inst.Dictionaries = []Dictionary{dictionary(dict)}
+			}
+		}
+
+		idx, exists := genericByName[baseName]
+		if !exists {
+			idx = len(generics)
+			genericByName[baseName] = idx
+			generics = append(generics, GenericFunction{Name: baseName, SourceLine: fn.SourceLine})
+		}
+		g := &generics[idx]
+		if g.SourceLine == 0 || (fn.SourceLine != 0 && fn.SourceLine < g.SourceLine) {
+			g.SourceLine = fn.SourceLine
+		}
+		g.Instantiations = append(g.Instantiations, inst)
+	}
+
+	// Dictionaries can be passed to shape instantiations by wrappers as well as by any other
+	// function that calls them directly, e.g. after inlining a wrapper.
+	addDictionary := func(call dictionaryCall) {
+		dicts := shapeDictionaries[call.Shape]
+		if !slices.ContainsFunc(dicts, func(d Dictionary) bool { return d.Name == call.Dictionary }) {
+			shapeDictionaries[call.Shape] = append(dicts, dictionary(call.Dictionary))
+		}
+	}
+	for _, fn := range functions {
+		if call, ok := info.wrapperCalls[strings.TrimPrefix(fn.Symbol, "main.")]; ok {
+			addDictionary(call)
+		}
+		for _, call := range dictionaryCalls(fn.Code) {
+			addDictionary(call)
+		}
+	}
+	for i := range generics {
+		for j := range generics[i].Instantiations {
+			inst := &generics[i].Instantiations[j]
+			if inst.Shape {
+				inst.Dictionaries = shapeDictionaries[inst.Name]
+			}
+		}
+	}
+
+	return generics
+}
+
+type dictionaryCall struct {
+	Shape      string
+	Dictionary string
+}
+
+// dictionaryCalls finds calls of shape instantiations along with dictionaries loaded for them.
+func dictionaryCalls(code []string) []dictionaryCall {
+	var calls []dictionaryCall
+	lastDictionary := ""
+	for _, line := range code {
+		if match := reDictionarySymbol.FindStringSubmatch(line); match != nil {
+			lastDictionary = match[reDictionarySymbol_Name]
+			continue
+		}
+		target, ok := strings.CutPrefix(line, "CALL main.")
+		if !ok || lastDictionary == "" {
+			continue
+		}
+		target = strings.TrimSuffix(target, "(SB)")
+		if _, typeArgs, ok := splitInstantiation(target); ok && isShapeInstantiation(typeArgs) {
+			calls = append(calls, dictionaryCall{Shape: target, Dictionary: lastDictionary})
+		}
+		lastDictionary = ""
+	}
+	return calls
+}
+
+// splitInstantiation splits instantiated function name into generic function name and type arguments,
+// e.g. "(*Stack[go.shape.int]).Push" into "(*Stack).Push" and ["go.shape.int"].
+func splitInstantiation(name string) (string, []string, bool) {
+	start := strings.IndexByte(name, '[')
+	if start == -1 {
+		return name, nil, false
+	}
+	depth := 0
+	argStart := start + 1
+	var typeArgs []string
+	for i := start; i < len(name); i++ {
+		switch name[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+			if depth == 0 {
+				typeArgs = append(typeArgs, name[argStart:i])
+				return name[:start] + name[i+1:], typeArgs, true
+			}
+		case ',':
+			if depth == 1 {
+				typeArgs = append(typeArgs, name[argStart:i])
+				argStart = i + 1
+			}
+		}
+	}
+	return name, nil, false
+}
+
+func isShapeInstantiation(typeArgs []string) bool {
+	for _, arg := range typeArgs {
+		if strings.HasPrefix(arg, "go.shape.") {
+			return true
+		}
+	}
+	return false
+}
+
+// baseFunctionName strips type arguments from an instantiated function name.
+func baseFunctionName(name string) string {
+	if baseName, _, ok := splitInstantiation(name); ok {
+		return baseName
+	}
+	return name
+}
+
+// parseDictionaryEntries returns the number of dictionary entries of a shape instantiation
+// from its inlining signature, e.g. "func(*[7]uintptr, []go.shape.int) ...".
+func parseDictionaryEntries(text []byte) (int, bool) {
+	match := reDictionaryParam.FindSubmatch(text)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(match[reDictionaryParam_Entries]))
+	return n, err == nil
+}
+
+// parseWrapperCall returns the shape instantiation call from inlining body of a wrapper, e.g.
+// "func([]int) int { return Sum[go.shape.int](&.dict.Sum[int], s) }".
+func parseWrapperCall(text []byte) (dictionaryCall, bool) {
+	match := reWrapperCall.FindSubmatch(text)
+	if match == nil {
+		return dictionaryCall{}, false
+	}
+	return dictionaryCall{
+		Shape:      string(match[reWrapperCall_Shape]),
+		Dictionary: string(match[reWrapperCall_Dictionary]),
+	}, true
+}
+
+// dictionaryName returns the name of the dictionary for a wrapper instantiation,
+// e.g. "Stack[int]" for "(*Stack[int]).Push".
+func dictionaryName(name string) string {
+	_, typeArgs, ok := splitInstantiation(name)
+	if !ok {
+		return ""
+	}
+	prefix := strings.TrimLeft(name[:strings.IndexByte(name, '[')], "(*")
+	return prefix + "[" + strings.Join(typeArgs, ",") + "]"
+}
+
+// parseDictionarySymbol parses dictionary data symbol header,
+// e.g. "main..dict.Map[int,string] SRODATA dupok size=56".
+func parseDictionarySymbol(line []byte) (string, int, bool) {
+	match := reDictionaryData.FindSubmatch(line)
+	if match == nil {
+		return "", 0, false
+	}
+	size, err := strconv.Atoi(string(match[reDictionaryData_Size]))
+	return string(match[reDictionaryData_Name]), size, err == nil
+}
+
+var reDictionarySymbol = regexp.MustCompile(`main\.\.dict\.(.+?)\(SB\)`)
+
+const (
+	reDictionarySymbol_Name = iota + 1
+)
+
+var reDictionaryParam = regexp.MustCompile(` as: (?:method\(.*?\) )?func\(\*\[(\d+)\]uintptr`)
+
+const (
+	reDictionaryParam_Entries = iota + 1
+)
+
+var reWrapperCall = regexp.MustCompile(`\{ (?:return )?(\S+?)\((?:\w+, )?&\.dict\.(\w+\[\S*?\])[,)]`)
+
+const (
+	reWrapperCall_Shape = iota + 1
+	reWrapperCall_Dictionary
+)
+
+var reCanInlineInstantiation = regexp.MustCompile(`^can inline (\S*\[.*?\]\S*) with cost \d+ as: `)
+
+const (
+	reCanInlineInstantiation_Name = iota + 1
+)
+
+var reDictionaryData = regexp.MustCompile(`^main\.\.dict\.(\S+) SRODATA .*?size=(\d+)`)
+
+const (
+	reDictionaryData_Name = iota + 1
+	reDictionaryData_Size
+)
@@ -0,0 +1,76 @@
+package parsers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitInstantiation(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseName string
+		typeArgs []string
+		ok       bool
+	}{
+		{"main", "main", nil, false},
+		{"Map[go.shape.int,go.shape.string]", "Map", []string{"go.shape.int", "go.shape.string"}, true},
+		{"(*Stack[go.shape.*uint8]).Push", "(*Stack).Push", []string{"go.shape.*uint8"}, true},
+		{"Map[go.shape.int].func1", "Map.func1", []string{"go.shape.int"}, true},
+		{"Keys[map[string]int,go.shape.struct { a int; b int }]", "Keys", []string{"map[string]int", "go.shape.struct { a int; b int }"}, true},
+		{"Broken[int", "Broken[int", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseName, typeArgs, ok := splitInstantiation(tt.name)
+			if baseName != tt.baseName || !slices.Equal(typeArgs, tt.typeArgs) || ok != tt.ok {
+				t.Errorf("got %q %q %v, want %q %q %v", baseName, typeArgs, ok, tt.baseName, tt.typeArgs, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseGenerics(t *testing.T) {
+	source := "package main\n\nfunc Sum[T int | float64](s []T) T {\n\tvar sum T\n\tfor _, v := range s {\n\t\tsum += v\n\t}\n\treturn sum\n}\n"
+	output := strings.Join([]string{
+		"./main.go:3:6: can inline Sum[go.shape.int] with cost 21 as: func(*[3]uintptr, []go.shape.int) go.shape.int { return sum }",
+		"main.Sum[go.shape.int] STEXT dupok nosplit size=32 args=0x20 locals=0x0 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:3)\tTEXT\tmain.Sum[go.shape.int](SB), DUPOK|NOSPLIT|ABIInternal, $0-32",
+		"\t0x0000 00000 (./main.go:6)\tADDQ\t(BX)(DX*8), SI",
+		"\t0x0004 00004 (./main.go:8)\tRET",
+		"main.main STEXT size=64 args=0x0 locals=0x20 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:11)\tTEXT\tmain.main(SB), ABIInternal, $32-0",
+		"\t0x0004 00004 (./main.go:12)\tLEAQ\tmain..dict.Sum[int](SB), AX",
+		"\t0x000b 00011 (./main.go:12)\tCALL\tmain.Sum[go.shape.int](SB)",
+		"\t0x0010 00016 (./main.go:13)\tRET",
+		"main..dict.Sum[int] SRODATA dupok size=24",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	if len(res.Generics) != 1 {
+		t.Fatalf("expected 1 generic function, got %d", len(res.Generics))
+	}
+	g := res.Generics[0]
+	if g.Name != "Sum" || g.SourceLine != 3 || len(g.Instantiations) != 1 {
+		t.Fatalf("unexpected generic function: %+v", g)
+	}
+	inst := g.Instantiations[0]
+	if !inst.Shape || inst.DictionaryEntries != 3 || inst.AssemblyStart != 1 || inst.AssemblyEnd != 3 {
+		t.Errorf("unexpected instantiation: %+v", inst)
+	}
+	want := []Dictionary{{Name: "Sum[int]", Size: 24}}
+	if !slices.Equal(inst.Dictionaries, want) {
+		t.Errorf("expected dictionaries %+v, got %+v", want, inst.Dictionaries)
+	}
+
+	if len(res.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(res.Diagnostics))
+	}
+	ia, ok := res.Diagnostics[0].(InliningAnalysis)
+	if !ok || ia.Name != "Sum[go.shape.int]" || ia.Range.End.Column-ia.Range.Start.Column != len("Sum") {
+		t.Errorf("unexpected diagnostic: %+v", res.Diagnostics[0])
+	}
+}
@@ -0,0 +1,485 @@
+package parsers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// Inlining budget of the compiler, see inlineMaxBudget in cmd/compile/internal/inline.
+const inlineBudget = 80
+
+// Extra cost of a call that is not inlined, see inlineExtraCallCost in cmd/compile/internal/inline.
+const inlineExtraCallCost = 57
+
+// InlineCost is a part of inlining cost of a function attributed to a statement.
+type InlineCost struct {
+	Range Range `json:"range"`
+	Cost  int   `json:"cost"`
+}
+
+// parseInlineBudget parses cost and budget of a function too complex to inline, e.g.
+// "function too complex: cost 133 exceeds budget 80".
+func parseInlineBudget(reason []byte) (int, int, bool) {
+	match := reInlineBudget.FindSubmatch(reason)
+	if match == nil {
+		return 0, 0, false
+	}
+	cost, ok := parseInt(match[reInlineBudget_Cost])
+	if !ok {
+		return 0, 0, false
+	}
+	budget, ok := parseInt(match[reInlineBudget_Budget])
+	return cost, budget, ok
+}
+
+// attributeInlineCosts breaks down inlining costs of analyzed functions by statements.
+//
+// The compiler only reports the total cost, so the cost of each statement is estimated by
+// counting its syntax nodes the way the inliner counts IR nodes: calls that are not inlined
+// add the extra call cost and inlinable calls add the cost of the callee. Estimates are then
+// scaled to add up to the reported cost.
+//
+// Callees are matched by qualified names: functions by name and methods by the receiver type
+// and name, e.g. "T.String". Receivers are resolved from method expressions and from types of
+// receivers, parameters and variables evident from their declarations. Calls of methods with
+// an unknown receiver are only matched if a single type has an inlinable method of that name.
+func attributeInlineCosts(res *Result, sourceCode []byte, sourceLines [][]byte) {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "main.go", sourceCode, parser.SkipObjectResolution)
+	if file == nil {
+		return
+	}
+
+	types := declaredTypes(file)
+	callees := map[string]int{}
+	methods := map[string][]string{}
+	for _, d := range res.Diagnostics {
+		if ia, ok := d.(InliningAnalysis); ok && ia.CanInline {
+			name, recv, method := inlineCalleeName(ia.Name, types)
+			if _, exists := callees[name]; exists {
+				continue
+			}
+			callees[name] = ia.Cost
+			if recv != "" {
+				methods[method] = append(methods[method], name)
+			}
+		}
+	}
+	ce := costEstimator{callees: callees, methods: methods, types: types, packages: importedPackages(file)}
+
+	for i, d := range res.Diagnostics {
+		ia, ok := d.(InliningAnalysis)
+		if !ok || ia.Cost == 0 {
+			continue
+		}
+		fn, body := functionBody(fset, file, ia.Range.Start.Line)
+		if body == nil {
+			continue
+		}
+		ce.locals = ce.localTypes(fn)
+		var spans []costSpan
+		ce.block(body, &spans)
+		ia.Breakdown = scaleInlineCosts(fset, sourceLines, spans, ia.Cost)
+		res.Diagnostics[i] = ia
+	}
+}
+
+// functionBody returns the function declaration or literal starting at the line and its body.
+func functionBody(fset *token.FileSet, file *ast.File, line int) (ast.Node, *ast.BlockStmt) {
+	var fn ast.Node
+	var body *ast.BlockStmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		if body != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil && fset.PositionFor(n.Pos(), false).Line == line {
+				fn, body = n, n.Body
+			}
+		case *ast.FuncLit:
+			if fset.PositionFor(n.Pos(), false).Line == line {
+				fn, body = n, n.Body
+			}
+		}
+		return body == nil
+	})
+	return fn, body
+}
+
+// inlineCalleeName returns the qualified name of the function reported by the compiler, e.g.
+// "square" or "T.String" for "(*T).String" and "(*T[int]).String", with the receiver type
+// and method name if it is a method of one of the declared types.
+func inlineCalleeName(name string, types map[string]bool) (qualified, recv, method string) {
+	name = baseFunctionName(name)
+	if r, m, ok := strings.Cut(name, ")."); ok && strings.HasPrefix(r, "(") {
+		recv, method = strings.TrimPrefix(strings.TrimPrefix(r, "("), "*"), m
+	} else if i := strings.LastIndexByte(name, '.'); i != -1 {
+		recv, method = name[:i], name[i+1:]
+	}
+	if recv == "" || !types[recv] {
+		return name, "", ""
+	}
+	return recv + "." + method, recv, method
+}
+
+// importedPackages returns names the imported packages are referred to by in the file.
+func importedPackages(file *ast.File) map[string]bool {
+	packages := map[string]bool{}
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, "`\"")
+		name := path[strings.LastIndexByte(path, '/')+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		packages[name] = true
+	}
+	return packages
+}
+
+// declaredTypes returns names of types declared in the file.
+func declaredTypes(file *ast.File) map[string]bool {
+	types := map[string]bool{}
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			types[spec.(*ast.TypeSpec).Name.Name] = true
+		}
+	}
+	return types
+}
+
+// costSpan is a statement, or a header of a compound statement, with its estimated cost.
+type costSpan struct {
+	Start, End token.Pos
+	Cost       int
+}
+
+type costEstimator struct {
+	callees  map[string]int      // Costs of inlinable functions by qualified name.
+	methods  map[string][]string // Qualified names of inlinable methods by method name.
+	types    map[string]bool     // Declared types.
+	packages map[string]bool     // Names of imported packages.
+	locals   map[string]string   // Types of local variables of the function, empty if ambiguous.
+}
+
+// localTypes returns declared types of the receiver, parameters and local variables of the
+// function, where they are evident from the declarations.
+func (ce costEstimator) localTypes(fn ast.Node) map[string]string {
+	locals := map[string]string{}
+	declare := func(name *ast.Ident, typ string) {
+		if name == nil || name.Name == "_" {
+			return
+		}
+		if prev, ok := locals[name.Name]; ok && prev != typ {
+			typ = ""
+		}
+		locals[name.Name] = typ
+	}
+	fields := func(list *ast.FieldList) {
+		if list == nil {
+			return
+		}
+		for _, f := range list.List {
+			for _, name := range f.Names {
+				declare(name, ce.typeName(f.Type))
+			}
+		}
+	}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			fields(n.Recv)
+		case *ast.FuncType:
+			fields(n.Params)
+			fields(n.Results)
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				typ := ce.typeName(n.Type)
+				if n.Type == nil && i < len(n.Values) {
+					typ = ce.exprType(n.Values[i])
+				}
+				declare(name, typ)
+			}
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE || len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				if name, ok := lhs.(*ast.Ident); ok {
+					declare(name, ce.exprType(n.Rhs[i]))
+				}
+			}
+		}
+		return true
+	})
+	return locals
+}
+
+// typeName returns the name of the declared type, possibly a pointer or an instantiation.
+func (ce costEstimator) typeName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if ce.types[e.Name] {
+			return e.Name
+		}
+	case *ast.StarExpr:
+		return ce.typeName(e.X)
+	case *ast.ParenExpr:
+		return ce.typeName(e.X)
+	case *ast.IndexExpr:
+		return ce.typeName(e.X)
+	case *ast.IndexListExpr:
+		return ce.typeName(e.X)
+	}
+	return ""
+}
+
+// exprType returns the declared type of the expression, if it is evident from the expression:
+// composite literals, their addresses, new and conversions.
+func (ce costEstimator) exprType(e ast.Expr) string {
+	switch e := ast.Unparen(e).(type) {
+	case *ast.CompositeLit:
+		return ce.typeName(e.Type)
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return ce.exprType(e.X)
+		}
+	case *ast.CallExpr:
+		if id, ok := e.Fun.(*ast.Ident); ok && id.Name == "new" && len(e.Args) == 1 {
+			return ce.typeName(e.Args[0])
+		}
+		return ce.typeName(e.Fun)
+	}
+	return ""
+}
+
+func (ce costEstimator) block(b *ast.BlockStmt, spans *[]costSpan) {
+	for _, s := range b.List {
+		ce.stmt(s, spans)
+	}
+}
+
+// stmt estimates costs of simple statements, and of headers and bodies of compound statements separately.
+func (ce costEstimator) stmt(s ast.Stmt, spans *[]costSpan) {
+	header := func(start, end token.Pos, nodes ...ast.Node) {
+		cost := 1
+		for _, n := range nodes {
+			cost += ce.nodes(n)
+		}
+		*spans = append(*spans, costSpan{Start: start, End: end, Cost: cost})
+	}
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		ce.block(s, spans)
+	case *ast.LabeledStmt:
+		ce.stmt(s.Stmt, spans)
+	case *ast.IfStmt:
+		header(s.If, s.Body.Lbrace, s.Init, s.Cond)
+		ce.block(s.Body, spans)
+		if s.Else != nil {
+			ce.stmt(s.Else, spans)
+		}
+	case *ast.ForStmt:
+		header(s.For, s.Body.Lbrace, s.Init, s.Cond, s.Post)
+		ce.block(s.Body, spans)
+	case *ast.RangeStmt:
+		header(s.For, s.Body.Lbrace, s.Key, s.Value, s.X)
+		ce.block(s.Body, spans)
+	case *ast.SwitchStmt:
+		header(s.Switch, s.Body.Lbrace, s.Init, s.Tag)
+		ce.clauses(s.Body, spans)
+	case *ast.TypeSwitchStmt:
+		header(s.Switch, s.Body.Lbrace, s.Init, s.Assign)
+		ce.clauses(s.Body, spans)
+	case *ast.SelectStmt:
+		header(s.Select, s.Body.Lbrace)
+		ce.clauses(s.Body, spans)
+	case *ast.EmptyStmt:
+	default:
+		*spans = append(*spans, costSpan{Start: s.Pos(), End: s.End(), Cost: ce.nodes(s)})
+	}
+}
+
+func (ce costEstimator) clauses(body *ast.BlockStmt, spans *[]costSpan) {
+	for _, c := range body.List {
+		switch c := c.(type) {
+		case *ast.CaseClause:
+			nodes := make([]ast.Node, 0, len(c.List))
+			for _, e := range c.List {
+				nodes = append(nodes, e)
+			}
+			*spans = append(*spans, costSpan{Start: c.Case, End: c.Colon + 1, Cost: 1 + ce.sum(nodes)})
+			for _, s := range c.Body {
+				ce.stmt(s, spans)
+			}
+		case *ast.CommClause:
+			*spans = append(*spans, costSpan{Start: c.Case, End: c.Colon + 1, Cost: 1 + ce.nodes(c.Comm)})
+			for _, s := range c.Body {
+				ce.stmt(s, spans)
+			}
+		}
+	}
+}
+
+func (ce costEstimator) sum(nodes []ast.Node) int {
+	cost := 0
+	for _, n := range nodes {
+		cost += ce.nodes(n)
+	}
+	return cost
+}
+
+// nodes estimates the cost of the syntax tree.
+func (ce costEstimator) nodes(root ast.Node) int {
+	if root == nil {
+		return 0
+	}
+	cost := 0
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case nil:
+			return false
+		case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+			return false
+		case *ast.ParenExpr:
+			return true
+		case *ast.CompositeLit:
+			cost++
+			for _, e := range n.Elts {
+				cost += ce.nodes(e)
+			}
+			return false
+		case *ast.CallExpr:
+			cost += ce.call(n)
+		default:
+			cost++
+		}
+		return true
+	})
+	return cost
+}
+
+// call estimates the cost of the call itself, excluding its arguments.
+func (ce costEstimator) call(call *ast.CallExpr) int {
+	var name string
+	fun := ast.Unparen(call.Fun)
+	// Instantiation of a generic function.
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		fun = f.X
+	case *ast.IndexListExpr:
+		fun = f.X
+	}
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		name = fun.Name
+		if builtinFuncs[name] || builtinTypes[name] || ce.types[name] {
+			return 1
+		}
+	case *ast.SelectorExpr:
+		name = ce.methodName(fun)
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StarExpr:
+		// Conversion.
+		return 1
+	}
+	if cost, ok := ce.callees[name]; ok {
+		return 1 + cost
+	}
+	return 1 + inlineExtraCallCost
+}
+
+// methodName returns the qualified name of the called method, or an empty string if it is
+// not a method of a declared type or its receiver is ambiguous.
+func (ce costEstimator) methodName(sel *ast.SelectorExpr) string {
+	method := sel.Sel.Name
+	if x, ok := ast.Unparen(sel.X).(*ast.Ident); ok {
+		switch {
+		case ce.types[x.Name]:
+			// Method expression.
+			return x.Name + "." + method
+		case ce.locals[x.Name] != "":
+			return ce.locals[x.Name] + "." + method
+		case ce.packages[x.Name]:
+			// Functions of other packages are not analyzed.
+			return ""
+		}
+	} else if typ := ce.exprType(sel.X); typ != "" {
+		return typ + "." + method
+	}
+	if candidates := ce.methods[method]; len(candidates) == 1 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// scaleInlineCosts scales estimated costs of statements to add up to the total cost,
+// distributing the rounding error by largest remainder.
+func scaleInlineCosts(fset *token.FileSet, sourceLines [][]byte, spans []costSpan, total int) []InlineCost {
+	estimated := 0
+	for _, s := range spans {
+		estimated += s.Cost
+	}
+	if estimated == 0 {
+		return nil
+	}
+
+	costs := make([]InlineCost, len(spans))
+	remainders := make([]int, len(spans))
+	assigned := 0
+	for i, s := range spans {
+		costs[i] = InlineCost{
+			Range: spanRange(fset, sourceLines, s.Start, s.End),
+			Cost:  s.Cost * total / estimated,
+		}
+		remainders[i] = s.Cost * total % estimated
+		assigned += costs[i].Cost
+	}
+	for ; assigned < total; assigned++ {
+		best := 0
+		for i := range remainders {
+			if remainders[i] > remainders[best] {
+				best = i
+			}
+		}
+		costs[best].Cost++
+		remainders[best] = -1
+	}
+	return costs
+}
+
+func spanRange(fset *token.FileSet, sourceLines [][]byte, start, end token.Pos) Range {
+	s, e := fset.PositionFor(start, false), fset.PositionFor(end, false)
+	r := Range{
+		Start: locationToUnicode(sourceLines, Location{Line: s.Line, Column: s.Column}),
+		End:   locationToUnicode(sourceLines, Location{Line: e.Line, Column: e.Column - 1}),
+	}
+	r.End.Column++
+	return r
+}
+
+var builtinFuncs = map[string]bool{
+	"append": true, "cap": true, "clear": true, "close": true, "complex": true, "copy": true,
+	"delete": true, "imag": true, "len": true, "make": true, "max": true, "min": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true, "recover": true,
+}
+
+var builtinTypes = map[string]bool{
+	"any": true, "bool": true, "byte": true, "complex64": true, "complex128": true, "error": true,
+	"float32": true, "float64": true, "int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"rune": true, "string": true, "uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true,
+}
+
+var reInlineBudget = regexp.MustCompile(`cost (\d+) exceeds budget (\d+)`)
+
+const (
+	reInlineBudget_Cost = iota + 1
+	reInlineBudget_Budget
+)
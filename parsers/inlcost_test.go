@@ -0,0 +1,147 @@
+package parsers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttributeInlineCosts(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"func square(x int) int {",
+		"\treturn x * x",
+		"}",
+		"",
+		"func sum(s []int) int {",
+		"\ttotal := 0",
+		"\tfor _, v := range s {",
+		"\t\ttotal += square(v)",
+		"\t}",
+		"\tprintln(total)",
+		"\tsink(total)",
+		"\treturn total",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"./main.go:3:6: can inline square with cost 4 as: func(int) int { return x * x }",
+		"./main.go:7:6: cannot inline sum: function too complex: cost 92 exceeds budget 80",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	var analyses []InliningAnalysis
+	for _, d := range res.Diagnostics {
+		if ia, ok := d.(InliningAnalysis); ok {
+			analyses = append(analyses, ia)
+		}
+	}
+	if len(analyses) != 2 {
+		t.Fatalf("expected 2 inlining analyses, got %d", len(analyses))
+	}
+
+	square := analyses[0]
+	if square.Cost != 4 || square.Budget != inlineBudget {
+		t.Errorf("expected square cost 4 and budget %d, got %d and %d", inlineBudget, square.Cost, square.Budget)
+	}
+	wantSquare := []InlineCost{{Range{Location{4, 2}, Location{4, 14}}, 4}}
+	if !reflect.DeepEqual(square.Breakdown, wantSquare) {
+		t.Errorf("expected square breakdown %+v, got %+v", wantSquare, square.Breakdown)
+	}
+
+	sum := analyses[1]
+	if sum.Cost != 92 || sum.Budget != 80 {
+		t.Errorf("expected sum cost 92 and budget 80, got %d and %d", sum.Cost, sum.Budget)
+	}
+	total, maxLine, maxCost := 0, 0, 0
+	for _, c := range sum.Breakdown {
+		total += c.Cost
+		if c.Cost > maxCost {
+			maxLine, maxCost = c.Range.Start.Line, c.Cost
+		}
+	}
+	if len(sum.Breakdown) != 6 || total != 92 {
+		t.Errorf("expected 6 statements costing 92 in total, got %+v", sum.Breakdown)
+	}
+	if maxLine != 13 {
+		t.Errorf("expected call of sink to be the most expensive, got line %d", maxLine)
+	}
+	if header := sum.Breakdown[1].Range; header != (Range{Location{9, 2}, Location{9, 22}}) {
+		t.Errorf("expected range loop header range, got %+v", header)
+	}
+}
+
+func TestInlineCalleeCosts(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"import \"strings\"",
+		"",
+		"type T struct{}",
+		"type U struct{}",
+		"type V struct{}",
+		"",
+		"func String() string    { return \"\" }",
+		"func (T) String() string { return \"T\" }",
+		"func (*U) String() string { return \"U\" }",
+		"func (V) Len() int        { return 0 }",
+		"",
+		"func f(t T, x interface{ String() string }) {",
+		"\tu := &U{}",
+		"\tString()",
+		"\tt.String()",
+		"\tu.String()",
+		"\tT.String(t)",
+		"\tx.String()",
+		"\tV{}.Len()",
+		"\tx.Len()",
+		"\tstrings.ToUpper(\"\")",
+		"}",
+	}, "\n")
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", source, parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := declaredTypes(file)
+	ce := costEstimator{callees: map[string]int{}, methods: map[string][]string{}, types: types, packages: importedPackages(file)}
+	for name, cost := range map[string]int{"String": 2, "T.String": 3, "(*U).String": 4, "V.Len": 5} {
+		qualified, recv, method := inlineCalleeName(name, types)
+		ce.callees[qualified] = cost
+		if recv != "" {
+			ce.methods[method] = append(ce.methods[method], qualified)
+		}
+	}
+	fn, _ := functionBody(fset, file, 14)
+	ce.locals = ce.localTypes(fn)
+
+	var got []int
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			got = append(got, ce.call(call))
+			return false
+		}
+		return true
+	})
+	// Call of a method of an unknown receiver is ambiguous between T and U, but not for V.
+	want := []int{1 + 2, 1 + 3, 1 + 4, 1 + 3, 1 + inlineExtraCallCost, 1 + 5, 1 + 5, 1 + inlineExtraCallCost}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected call costs %v, got %v", want, got)
+	}
+}
+
+func TestParseInlineBudget(t *testing.T) {
+	cost, budget, ok := parseInlineBudget([]byte("function too complex: cost 133 exceeds budget 80"))
+	if !ok || cost != 133 || budget != 80 {
+		t.Errorf("parseInlineBudget() = %d, %d, %v, want 133, 80, true", cost, budget, ok)
+	}
+	if _, _, ok := parseInlineBudget([]byte("marked go:noinline")); ok {
+		t.Errorf("expected no budget for go:noinline")
+	}
+}
@@ -0,0 +1,128 @@
+package parsers
+
+import "strings"
+
+// InlineNode is a call inlined into a function. Calls inlined into the body of
+// the inlined callee are its children.
+type InlineNode struct {
+	Name string `json:"name"`
+	// Call site, in the body of the parent callee for nested calls.
+	Range Range `json:"range"`
+	// Inlining cost of the callee, if known.
+	Cost  int          `json:"cost,omitempty"`
+	Calls []InlineNode `json:"calls,omitempty"`
+}
+
+// buildInlineTrees fills inline trees of res.Functions, which must correspond to the
+// functions found in the assembly.
+//
+// The compiler reports calls inlined into an inlined body at the outermost call site,
+// so the first call reported at a site is the direct one and the rest are nested. Nested
+// calls are placed by matching them against the calls inlined into the callee itself.
+// Those that can not be placed, e.g. calls within other packages, are attached to the
+// direct call.
+func buildInlineTrees(res *Result, functions []asmFunction) {
+	costs := map[string]int{}
+	for _, d := range res.Diagnostics {
+		if ia, ok := d.(InliningAnalysis); ok && ia.CanInline {
+			if _, exists := costs[ia.Name]; !exists {
+				costs[ia.Name] = ia.Cost
+			}
+		}
+	}
+
+	spans := functionSpans(functions)
+	sites := make([][]inlineSite, len(functions))
+	siteIndex := map[Range]int{}
+	for _, d := range res.Diagnostics {
+		ic, ok := d.(InlinedCall)
+		if !ok {
+			continue
+		}
+		i, ok := spans.find(ic.Range.Start.Line)
+		if !ok {
+			continue
+		}
+		if j, ok := siteIndex[ic.Range]; ok {
+			sites[i][j].Nested = append(sites[i][j].Nested, ic.Name)
+			continue
+		}
+		siteIndex[ic.Range] = len(sites[i])
+		sites[i] = append(sites[i], inlineSite{Name: ic.Name, Range: ic.Range})
+	}
+
+	b := inlineTreeBuilder{
+		costs:     costs,
+		sites:     sites,
+		functions: map[string]int{},
+		trees:     make([][]InlineNode, len(functions)),
+		state:     make([]int, len(functions)),
+	}
+	for i, fn := range functions {
+		name := strings.TrimPrefix(baseFunctionName(fn.Symbol), "main.")
+		if _, exists := b.functions[name]; !exists {
+			b.functions[name] = i
+		}
+	}
+	for i := range functions {
+		res.Functions[i].InlineTree = b.tree(i)
+	}
+}
+
+// inlineSite is a call site with the calls inlined there in the order they are reported.
+type inlineSite struct {
+	Name   string
+	Range  Range
+	Nested []string
+}
+
+type inlineTreeBuilder struct {
+	costs     map[string]int // Inlining costs by function name.
+	sites     [][]inlineSite // Inlining call sites of each function.
+	functions map[string]int // Function indices by name.
+	trees     [][]InlineNode // Built trees of each function.
+	state     []int          // 0 if the tree is not built, 1 if it is being built, 2 if it is built.
+}
+
+// tree returns the inline tree of the function.
+func (b *inlineTreeBuilder) tree(i int) []InlineNode {
+	if b.state[i] != 0 {
+		return b.trees[i]
+	}
+	b.state[i] = 1
+	var nodes []InlineNode
+	for _, site := range b.sites[i] {
+		node := InlineNode{Name: site.Name, Range: site.Range, Cost: b.costs[site.Name]}
+		pending := map[string]int{}
+		for _, name := range site.Nested {
+			pending[name]++
+		}
+		if callee, ok := b.functions[site.Name]; ok && b.state[callee] != 1 {
+			node.Calls = prune(b.tree(callee), pending)
+		}
+		for _, name := range site.Nested {
+			if pending[name] > 0 {
+				pending[name]--
+				node.Calls = append(node.Calls, InlineNode{Name: name, Range: site.Range, Cost: b.costs[name]})
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	b.trees[i] = nodes
+	b.state[i] = 2
+	return nodes
+}
+
+// prune returns nodes of the tree reported as pending, consuming them.
+func prune(tree []InlineNode, pending map[string]int) []InlineNode {
+	var nodes []InlineNode
+	for _, n := range tree {
+		if pending[n.Name] == 0 {
+			continue
+		}
+		pending[n.Name]--
+		n.Calls = prune(n.Calls, pending)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
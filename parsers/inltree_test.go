@@ -0,0 +1,75 @@
+package parsers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildInlineTrees(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"import \"strings\"",
+		"",
+		"func leaf(x int) int {",
+		"\treturn x * x",
+		"}",
+		"",
+		"func mid(x int) int {",
+		"\treturn leaf(x) + leaf(x+1)",
+		"}",
+		"",
+		"func main() {",
+		"\tprintln(mid(3))",
+		"\tprintln(strings.Index(\"ab\", \"b\"))",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"./main.go:5:6: can inline leaf with cost 4 as: func(int) int { return x * x }",
+		"./main.go:9:6: can inline mid with cost 18 as: func(int) int { return leaf(x) + leaf(x + 1) }",
+		"./main.go:10:13: inlining call to leaf",
+		"./main.go:10:23: inlining call to leaf",
+		"./main.go:14:13: inlining call to mid",
+		"./main.go:15:22: inlining call to strings.Index",
+		"./main.go:14:13: inlining call to leaf",
+		"./main.go:15:22: inlining call to bytealg.IndexByteString",
+		"./main.go:14:13: inlining call to leaf",
+		"main.leaf STEXT nosplit size=5 args=0x8 locals=0x0 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:5)\tTEXT\tmain.leaf(SB), NOSPLIT|ABIInternal, $0-8",
+		"\t0x0000 00000 (./main.go:6)\tIMULQ\tAX, AX",
+		"\t0x0004 00004 (./main.go:6)\tRET",
+		"main.mid STEXT nosplit size=16 args=0x8 locals=0x0 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:9)\tTEXT\tmain.mid(SB), NOSPLIT|ABIInternal, $0-8",
+		"\t0x0000 00000 (./main.go:10)\tRET",
+		"main.main STEXT size=82 args=0x0 locals=0x10 funcid=0x0 align=0x0",
+		"\t0x0000 00000 (./main.go:13)\tTEXT\tmain.main(SB), ABIInternal, $16-0",
+		"\t0x000e 00014 (./main.go:14)\tCALL\truntime.printint(SB)",
+		"\t0x0020 00032 (./main.go:15)\tCALL\truntime.printint(SB)",
+		"\t0x0045 00069 (./main.go:16)\tRET",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	if len(res.Functions) != 3 {
+		t.Fatalf("expected 3 functions, got %d", len(res.Functions))
+	}
+	if tree := res.Functions[0].InlineTree; tree != nil {
+		t.Errorf("expected no inlined calls in leaf, got %+v", tree)
+	}
+	leaf1 := InlineNode{Name: "leaf", Range: Range{Location{10, 9}, Location{10, 13}}, Cost: 4}
+	leaf2 := InlineNode{Name: "leaf", Range: Range{Location{10, 19}, Location{10, 23}}, Cost: 4}
+	if want := []InlineNode{leaf1, leaf2}; !reflect.DeepEqual(res.Functions[1].InlineTree, want) {
+		t.Errorf("expected mid inline tree %+v, got %+v", want, res.Functions[1].InlineTree)
+	}
+	index := Range{Location{15, 18}, Location{15, 22}}
+	want := []InlineNode{
+		{Name: "mid", Range: Range{Location{14, 10}, Location{14, 13}}, Cost: 18, Calls: []InlineNode{leaf1, leaf2}},
+		{Name: "strings.Index", Range: index, Calls: []InlineNode{{Name: "bytealg.IndexByteString", Range: index}}},
+	}
+	if got := res.Functions[2].InlineTree; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected main inline tree %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,160 @@
+package parsers
+
+import (
+	"bytes"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// lineDirectives maps logical source positions, as set by //line and /*line*/
+// directives, back to physical positions in the source code.
+type lineDirectives struct {
+	sections []lineSection
+}
+
+// lineSection is a part of the source code following a line directive.
+type lineSection struct {
+	physLine int // Physical line of the first position affected by the directive.
+	physCol  int // Physical column of the first position affected by the directive.
+
+	file string // Normalized logical filename.
+	line int    // Logical line of the first position.
+	col  int    // Logical column of the first position, zero if unknown.
+}
+
+const mainFilename = "main.go"
+
+func parseLineDirectives(sourceLines [][]byte) lineDirectives {
+	ld := lineDirectives{
+		sections: []lineSection{{physLine: 1, physCol: 1, file: mainFilename, line: 1, col: 1}},
+	}
+	for i, line := range sourceLines {
+		physLine := i + 1
+
+		// //line directives must start at the beginning of the line
+		// and set the position of the next line.
+		if text, ok := bytes.CutPrefix(line, []byte("//line ")); ok {
+			if s, ok := ld.parseDirective(string(text)); ok {
+				s.physLine, s.physCol = physLine+1, 1
+				ld.sections = append(ld.sections, s)
+			}
+			continue
+		}
+
+		// /*line*/ directives can appear anywhere and set the position
+		// of the character immediately following the comment.
+		offset := 0
+		for {
+			start := bytes.Index(line[offset:], []byte("/*line "))
+			if start == -1 {
+				break
+			}
+			start += offset
+			end := bytes.Index(line[start:], []byte("*/"))
+			if end == -1 {
+				break
+			}
+			end += start
+			if s, ok := ld.parseDirective(string(line[start+len("/*line ") : end])); ok {
+				s.physLine, s.physCol = physLine, end+len("*/")+1
+				ld.sections = append(ld.sections, s)
+			}
+			offset = end + len("*/")
+		}
+	}
+	return ld
+}
+
+// parseDirective parses directive text of the form filename:line[:col].
+func (ld *lineDirectives) parseDirective(text string) (lineSection, bool) {
+	text = strings.TrimSpace(text)
+	i := strings.LastIndexByte(text, ':')
+	if i == -1 {
+		return lineSection{}, false
+	}
+	n, err := strconv.Atoi(text[i+1:])
+	if err != nil || n <= 0 {
+		return lineSection{}, false
+	}
+	s := lineSection{line: n}
+	filename := text[:i]
+	if j := strings.LastIndexByte(filename, ':'); j != -1 {
+		if line, err := strconv.Atoi(filename[j+1:]); err == nil && line > 0 {
+			s.line, s.col = line, n
+			filename = filename[:j]
+		}
+	}
+	// An empty filename in //line :line:col form means the previous filename.
+	if filename == "" && s.col > 0 {
+		filename = ld.sections[len(ld.sections)-1].file
+	}
+	s.file = normalizeFilename(filename)
+	return s, true
+}
+
+// resolve returns physical location of the logical position. Column of the returned location
+// is zero if it is unknown. Origin is returned if the position is affected by a line directive.
+func (ld lineDirectives) resolve(file []byte, line, col int) (Location, *Origin, bool) {
+	filename := normalizeFilename(string(file))
+	// Later directives take precedence when logical positions are ambiguous.
+	for i := len(ld.sections) - 1; i >= 0; i-- {
+		s := ld.sections[i]
+		if s.file != filename || line < s.line {
+			continue
+		}
+		physLine := s.physLine + (line - s.line)
+		lastLine := math.MaxInt
+		if i+1 < len(ld.sections) {
+			next := ld.sections[i+1]
+			lastLine = next.physLine
+			if next.physCol == 1 {
+				lastLine--
+			}
+		}
+		if physLine > lastLine {
+			continue
+		}
+
+		loc := Location{Line: physLine}
+		switch {
+		case s.col == 0 || col == 0:
+		case physLine == s.physLine:
+			loc.Column = s.physCol + (col - s.col)
+		default:
+			loc.Column = col
+		}
+		if i == 0 {
+			return loc, nil, true
+		}
+		return loc, &Origin{File: s.file, Line: line, Column: col}, true
+	}
+	return Location{}, nil, false
+}
+
+// resolvePhysical returns physical location and, if a line directive
+// is in effect, logical origin of the assembly position.
+func (ld lineDirectives) resolvePhysical(file []byte, line int, physFile []byte, physLine int) (Location, *Origin, bool) {
+	if len(physFile) == 0 {
+		return ld.resolve(file, line, 0)
+	}
+	if normalizeFilename(string(physFile)) != mainFilename {
+		// Physical position is in a generated file, e.g. with cgo.
+		return ld.resolve(file, line, 0)
+	}
+	loc := Location{Line: physLine}
+	if normalizeFilename(string(file)) == mainFilename && line == physLine {
+		return loc, nil, true
+	}
+	return loc, &Origin{File: normalizeFilename(string(file)), Line: line}, true
+}
+
+// normalizeFilename makes filenames from line directives comparable
+// with the ones in compiler output.
+func normalizeFilename(filename string) string {
+	if filename == "" || filename == "??" {
+		return ""
+	}
+	return path.Clean(filename)
+}
@@ -0,0 +1,79 @@
+package parsers
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineDirectives(t *testing.T) {
+	src := strings.Join([]string{
+		"package main",                           // 1
+		"",                                       // 2
+		"//line gen.y:10",                        // 3
+		"func square(n int) int {",               // 4
+		"\treturn n * n",                         // 5
+		"}",                                      // 6
+		"//line :20",                             // 7
+		"func cube(n int) int {",                 // 8
+		"//line main.go:13",                      // 9
+		"func escape() *int {",                   // 10
+		"\tprintln(/*line calc.y:5:3*/ cube(3))", // 11
+		"}",                                      // 12
+	}, "\n")
+	ld := parseLineDirectives(bytes.Split([]byte(src), []byte{'\n'}))
+
+	tests := []struct {
+		file      string
+		line, col int
+
+		want       Location
+		wantOrigin *Origin
+		wantOK     bool
+	}{
+		{"./main.go", 2, 1, Location{Line: 2, Column: 1}, nil, true},
+		{"./gen.y", 10, 0, Location{Line: 4}, &Origin{File: "gen.y", Line: 10}, true},
+		{"gen.y", 11, 0, Location{Line: 5}, &Origin{File: "gen.y", Line: 11}, true},
+		{"gen.y", 14, 0, Location{}, nil, false},
+		{"??", 20, 0, Location{Line: 8}, &Origin{File: "", Line: 20}, true},
+		{"./main.go", 13, 0, Location{Line: 10}, &Origin{File: "main.go", Line: 13}, true},
+		{"./main.go", 14, 0, Location{Line: 11}, &Origin{File: "main.go", Line: 14}, true},
+		{"./calc.y", 5, 8, Location{Line: 11, Column: 34}, &Origin{File: "calc.y", Line: 5, Column: 8}, true},
+		{"_cgo_gotypes.go", 15, 6, Location{}, nil, false},
+	}
+	for _, tt := range tests {
+		got, origin, ok := ld.resolve([]byte(tt.file), tt.line, tt.col)
+		if ok != tt.wantOK || got != tt.want || !reflect.DeepEqual(origin, tt.wantOrigin) {
+			t.Errorf("resolve(%s:%d:%d) = %v, %+v, %v, want %v, %+v, %v",
+				tt.file, tt.line, tt.col, got, origin, ok, tt.want, tt.wantOrigin, tt.wantOK)
+		}
+	}
+}
+
+func TestParseCgoPositions(t *testing.T) {
+	src := []byte("package main\n\n// static int twice(int x) { return 2 * x; }\nimport \"C\"\n\nfunc main() {\n\tprintln(C.twice(3))\n}\n")
+	out := strings.Join([]string{
+		"_cgo_gotypes.go:47:6: cannot inline C.twice: marked go:cgo_unsafe_args",
+		"./main.go:6:6: cannot inline main: function too complex: cost 100 exceeds budget 80",
+		"\t0x0000 00000 (_cgo_gotypes.go:47)\tTEXT\tmain.C.twice(SB), ABIInternal, $40-16",
+		"\t0x0000 00000 (./main.go:6[main.cgo1.go:9])\tTEXT\tmain.main(SB), ABIInternal, $16-0",
+		"\t0x000e 00014 (./main.go:7[main.cgo1.go:10])\tMOVL\t$3, AX",
+		"\t0x0013 00019 (<unknown line number>)\tNOP",
+	}, "\n")
+	res := &Result{}
+	parseBuildOutput(res, src, strings.NewReader(out))
+	wantMapping := []Mapping{
+		{SourceLine: 6, AssemblyStart: 2, AssemblyEnd: 2},
+		{SourceLine: 7, AssemblyStart: 3, AssemblyEnd: 3},
+	}
+	if !reflect.DeepEqual(res.Mapping, wantMapping) {
+		t.Errorf("expected mapping %+v, got %+v", wantMapping, res.Mapping)
+	}
+	if len(res.Diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic, got %+v", res.Diagnostics)
+	}
+	if n := strings.Count(res.Assembly, "\n"); n != 4 {
+		t.Errorf("expected 4 assembly lines, got %d", n)
+	}
+}
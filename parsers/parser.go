@@ -2,7 +2,6 @@ package parsers
 
 import (
 	"encoding/gob"
-	"fmt"
 
 	"github.com/Masterminds/semver/v3"
 
@@ -18,16 +17,44 @@ type Result struct {
 	Assembly    string        `json:"assembly"`
 	Mapping     []Mapping     `json:"mapping"`
 	Diagnostics []IDiagnostic `json:"diagnostics"`
+	Warnings    []Warning     `json:"warnings,omitempty"`
+
+	Functions []Function        `json:"functions,omitempty"`
+	Generics  []GenericFunction `json:"generics,omitempty"`
 }
 
 type Mapping struct {
-	SourceLine    int `json:"source"`
-	AssemblyStart int `json:"start"`
-	AssemblyEnd   int `json:"end"`
+	SourceLine    int     `json:"source"`
+	AssemblyStart int     `json:"start"`
+	AssemblyEnd   int     `json:"end"`
+	Origin        *Origin `json:"origin,omitempty"`
+}
+
+// Origin is a logical source position set by a //line directive.
+type Origin struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
 }
 
+// Warning describes a part of the compiler output parser could not make sense of.
+type Warning struct {
+	Source  WarningSource `json:"source"`
+	Line    int           `json:"line"` // Line of the build output or record of the build JSON.
+	Text    string        `json:"text,omitempty"`
+	Message string        `json:"message"`
+}
+
+type WarningSource string
+
+const (
+	WarningSourceBuildOutput WarningSource = "buildOutput"
+	WarningSourceBuildJSON   WarningSource = "buildJSON"
+)
+
 // Can be one of:
-// [Diagnostic], [InliningAnalysis], [InlinedCall], [HeapEscape]
+// [Diagnostic], [InliningAnalysis], [InlinedCall], [HeapEscape], [Devirtualization],
+// [ClosureCapture], [FuncLiteral], [Spill], [Prove], [WriteBarrier], [StackCheck]
 type IDiagnostic any
 
 func init() {
@@ -35,11 +62,19 @@ func init() {
 	gob.Register(InliningAnalysis{})
 	gob.Register(InlinedCall{})
 	gob.Register(HeapEscape{})
+	gob.Register(Devirtualization{})
+	gob.Register(ClosureCapture{})
+	gob.Register(FuncLiteral{})
+	gob.Register(Spill{})
+	gob.Register(Prove{})
+	gob.Register(WriteBarrier{})
+	gob.Register(StackCheck{})
 }
 
 type Diagnostic struct {
-	Type  DiagnosticType `json:"type"`
-	Range Range          `json:"range"`
+	Type   DiagnosticType `json:"type"`
+	Range  Range          `json:"range"`
+	Origin *Origin        `json:"origin,omitempty"`
 }
 
 type DiagnosticType string
@@ -49,6 +84,13 @@ const (
 	DiagnosticInlinedCall      DiagnosticType = "inlinedCall"
 	DiagnosticHeapEscape       DiagnosticType = "heapEscape"
 	DiagnosticBoundsCheck      DiagnosticType = "boundsCheck"
+	DiagnosticDevirtualization DiagnosticType = "devirtualization"
+	DiagnosticClosureCapture   DiagnosticType = "closureCapture"
+	DiagnosticFuncLiteral      DiagnosticType = "funcLiteral"
+	DiagnosticSpill            DiagnosticType = "spill"
+	DiagnosticProve            DiagnosticType = "prove"
+	DiagnosticWriteBarrier     DiagnosticType = "writeBarrier"
+	DiagnosticStackCheck       DiagnosticType = "stackCheck"
 )
 
 type Range struct {
@@ -67,6 +109,9 @@ type InliningAnalysis struct {
 	CanInline bool   `json:"canInline"`
 	Reason    string `json:"reason"`
 	Cost      int    `json:"cost"`
+	Budget    int    `json:"budget,omitempty"`
+	// Estimated cost of statements of the function.
+	Breakdown []InlineCost `json:"breakdown,omitempty"`
 }
 
 type InlinedCall struct {
@@ -78,12 +123,103 @@ type HeapEscape struct {
 	Diagnostic
 	Name    string `json:"name"`
 	Message string `json:"message"`
+	// Function the value escapes in, reported by newer compilers.
+	Function string `json:"function,omitempty"`
+}
+
+// Devirtualization marks an interface method call that was devirtualized
+// or remains a dynamic dispatch.
+type Devirtualization struct {
+	Diagnostic
+	Kind   DevirtualizationKind `json:"kind"`
+	Call   string               `json:"call,omitempty"`
+	Target string               `json:"target,omitempty"`
 }
 
+type DevirtualizationKind string
+
+const (
+	// Call devirtualized because concrete type is known statically.
+	DevirtualizationStatic DevirtualizationKind = "static"
+	// Call devirtualized guarded by a type check of the hot callee from the PGO profile.
+	DevirtualizationPGO DevirtualizationKind = "pgo"
+	// Call dispatched dynamically through an interface method table.
+	DevirtualizationDynamic DevirtualizationKind = "dynamic"
+)
+
+// ClosureCapture marks a variable captured by a closure.
+type ClosureCapture struct {
+	Diagnostic
+	Name     string      `json:"name"`
+	Function string      `json:"function"` // Function containing the closure.
+	Mode     CaptureMode `json:"mode"`
+	Addr     bool        `json:"addr"`   // Address of the variable is taken.
+	Assign   bool        `json:"assign"` // Variable is reassigned.
+	Width    int         `json:"width"`  // Size of the variable in bytes.
+}
+
+type CaptureMode string
+
+const (
+	CaptureByValue CaptureMode = "value"
+	CaptureByRef   CaptureMode = "ref"
+)
+
+// FuncLiteral marks a function literal and whether it escapes to heap.
+type FuncLiteral struct {
+	Diagnostic
+	Escapes bool `json:"escapes"`
+}
+
+// Spill marks a value stored to the stack to survive a call and reloaded after it.
+type Spill struct {
+	Diagnostic
+	Name     string `json:"name"` // Variable or compiler temporary, e.g. ".autotmp_4".
+	Function string `json:"function"`
+	Call     string `json:"call"` // First call the value is live across.
+	Reloads  int    `json:"reloads"`
+}
+
+// Prove is a fact established by the prove pass of the SSA backend.
+type Prove struct {
+	Diagnostic
+	Kind      ProveKind `json:"kind"`
+	Op        string    `json:"op,omitempty"`        // Proved or disproved operation, e.g. IsInBounds or Less64.
+	Limits    string    `json:"limits,omitempty"`    // Limits of induction variable, e.g. [0,?).
+	Increment int       `json:"increment,omitempty"` // Increment of induction variable.
+}
+
+type ProveKind string
+
+const (
+	ProveProved            ProveKind = "proved"
+	ProveDisproved         ProveKind = "disproved"
+	ProveInductionVariable ProveKind = "inductionVariable"
+)
+
+// WriteBarrier marks an assignment storing pointers behind a write barrier check.
+type WriteBarrier struct {
+	Diagnostic
+	Functions []string `json:"functions"`
+	Checks    int      `json:"checks"` // Loads of the write barrier flag.
+	Calls     int      `json:"calls"`  // Calls of write barrier functions.
+}
+
+// StackCheck marks a function declaration with a stack bound check in the prologue,
+// which grows the stack or preempts the goroutine by calling morestack.
+type StackCheck struct {
+	Diagnostic
+	Function  string `json:"function"`
+	Frame     int    `json:"frame"`
+	Morestack string `json:"morestack"`
+}
+
+// FindMatching returns parser for the compiler version of the output
+// or nil if there is none.
 func FindMatching(output compilers.Result) Parser {
 	ver, err := semver.NewVersion(output.CompilerInfo.Version)
 	if err != nil {
-		panic(fmt.Sprintf("incorrect compiler version: %s", output.CompilerInfo.Version))
+		return nil
 	}
 	lastSupported := semver.MustParse("1.18")
 	switch {
@@ -0,0 +1,79 @@
+package parsers
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// parseProve parses messages of the prove pass enabled with -d=ssa/prove/debug=1, e.g.
+// "Proved IsInBounds" or "Induction variable: limits [0,?), increment 1".
+//
+// Bounds checks remaining after the prove pass, reported with -d=ssa/check_bce/debug=1
+// as "Found IsInBounds", are taken from the JSON diagnostics instead.
+func parseProve(text []byte) (Prove, bool) {
+	if match := reProved.FindSubmatch(text); match != nil {
+		kind := ProveProved
+		if string(match[reProved_Kind]) == "Disproved" {
+			kind = ProveDisproved
+		}
+		return Prove{
+			Diagnostic: Diagnostic{Type: DiagnosticProve},
+			Kind:       kind,
+			Op:         string(match[reProved_Op]),
+		}, true
+	}
+	if match := reInductionVariable.FindSubmatch(text); match != nil {
+		increment, _ := strconv.Atoi(string(match[reInductionVariable_Increment]))
+		return Prove{
+			Diagnostic: Diagnostic{Type: DiagnosticProve},
+			Kind:       ProveInductionVariable,
+			Limits:     string(match[reInductionVariable_Limits]),
+			Increment:  increment,
+		}, true
+	}
+	return Prove{}, false
+}
+
+// expressionLength returns the length of the index expression or identifier
+// starting at 1-based column col, or 1 if there is none.
+func expressionLength(line []byte, col int) int {
+	if col < 1 || col > len(line) {
+		return 1
+	}
+	rest := line[col-1:]
+	if rest[0] == '[' {
+		depth := 0
+		for i, c := range rest {
+			switch c {
+			case '[':
+				depth++
+			case ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return 1
+	}
+	if loc := reIdentifier.FindIndex(rest); loc != nil {
+		return loc[1]
+	}
+	return 1
+}
+
+var reProved = regexp.MustCompile(`^(Proved|Disproved) (\w+)`)
+
+const (
+	reProved_Kind = iota + 1
+	reProved_Op
+)
+
+var reInductionVariable = regexp.MustCompile(`^Induction variable: limits (\S+), increment (-?\d+)`)
+
+const (
+	reInductionVariable_Limits = iota + 1
+	reInductionVariable_Increment
+)
+
+var reIdentifier = regexp.MustCompile(`^\w+`)
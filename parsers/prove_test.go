@@ -0,0 +1,60 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProve(t *testing.T) {
+	source := strings.Join([]string{
+		"package main",
+		"",
+		"func sum(s []int) (t int) {",
+		"\tfor i := 0; i < len(s); i++ {",
+		"\t\tt += s[i]",
+		"\t}",
+		"\tif len(s) < 0 {",
+		"\t\treturn 0",
+		"\t}",
+		"\treturn t",
+		"}",
+	}, "\n")
+	output := strings.Join([]string{
+		"./main.go:4:21: Induction variable: limits [0,?), increment 1",
+		"./main.go:5:9: Proved IsInBounds",
+		"./main.go:5:9: Proved IsInBounds",
+		"./main.go:7:12: Disproved Less64",
+		"./main.go:5:9: Found IsInBounds",
+		"",
+	}, "\n")
+
+	var res Result
+	parseBuildOutput(&res, []byte(source), strings.NewReader(output))
+
+	want := []IDiagnostic{
+		Prove{
+			Diagnostic: Diagnostic{Type: DiagnosticProve, Range: Range{Location{4, 21}, Location{4, 22}}},
+			Kind:       ProveInductionVariable,
+			Limits:     "[0,?)",
+			Increment:  1,
+		},
+		Prove{
+			Diagnostic: Diagnostic{Type: DiagnosticProve, Range: Range{Location{5, 9}, Location{5, 12}}},
+			Kind:       ProveProved,
+			Op:         "IsInBounds",
+		},
+		Prove{
+			Diagnostic: Diagnostic{Type: DiagnosticProve, Range: Range{Location{7, 12}, Location{7, 13}}},
+			Kind:       ProveDisproved,
+			Op:         "Less64",
+		},
+	}
+	if len(res.Diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %+v", len(want), len(res.Diagnostics), res.Diagnostics)
+	}
+	for i := range want {
+		if res.Diagnostics[i] != want[i] {
+			t.Errorf("diagnostic %d: expected %+v, got %+v", i, want[i], res.Diagnostics[i])
+		}
+	}
+}
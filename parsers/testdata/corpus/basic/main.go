@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+func fibonacci(n int) int {
+	if n <= 1 {
+		return n
+	}
+	return fibonacci(n-1) + fibonacci(n-2)
+}
+
+func square(n int) int {
+	return n * n
+}
+
+func sqrt(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
+
+func main() {
+	res := fibonacci(3)
+	fmt.Println(res)
+	fmt.Println(sqrt(float32(res)))
+	fmt.Println(square(res))
+	os.Exit(s[42])
+}
+
+var s = make([]int, 100)
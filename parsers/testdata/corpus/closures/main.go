@@ -0,0 +1,41 @@
+package main
+
+type counter struct {
+	n int
+}
+
+func (c *counter) inc() {
+	c.n++
+}
+
+func makeAdder(base int) func(int) int {
+	return func(x int) int {
+		return base + x
+	}
+}
+
+//go:noinline
+func apply(xs []int, f func(int) int) {
+	for i := range xs {
+		xs[i] = f(xs[i])
+	}
+}
+
+func main() {
+	total := 0
+	xs := []int{1, 2, 3}
+	apply(xs, func(x int) int {
+		total += x
+		return x * 2
+	})
+
+	add := makeAdder(10)
+
+	var c counter
+	c.inc()
+	defer func() {
+		println("done", c.n)
+	}()
+
+	println(total, add(5), xs[0])
+}
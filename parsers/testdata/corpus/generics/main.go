@@ -0,0 +1,52 @@
+package main
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	r := make([]U, 0, len(s))
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func Sum[T Number](s []T) T {
+	var sum T
+	for _, v := range s {
+		sum += v
+	}
+	return sum
+}
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Push(v T) {
+	s.items = append(s.items, v)
+}
+
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v, true
+}
+
+func main() {
+	ints := []int{1, 2, 3}
+	strs := Map(ints, func(i int) string { return string(rune('a' + i)) })
+	floats := Map(ints, func(i int) float64 { return float64(i) / 2 })
+	println(Sum(ints), Sum(floats), len(strs))
+
+	var st Stack[*int]
+	st.Push(&ints[0])
+	if v, ok := st.Pop(); ok {
+		println(*v)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+type Shape interface {
+	Area() float64
+}
+
+type Rect struct {
+	W, H float64
+}
+
+func (r Rect) Area() float64 {
+	return r.W * r.H
+}
+
+type Circle struct {
+	R float64
+}
+
+func (c *Circle) Area() float64 {
+	return 3.14159 * c.R * c.R
+}
+
+//go:noinline
+func total(shapes []Shape) float64 {
+	sum := 0.0
+	for _, s := range shapes {
+		sum += s.Area()
+	}
+	return sum
+}
+
+func main() {
+	var s Shape = Rect{W: 2, H: 3}
+	println(s.Area())
+
+	shapes := []Shape{Rect{W: 1, H: 2}, &Circle{R: 3}}
+	println(total(shapes))
+}
@@ -0,0 +1,25 @@
+package main
+
+//line gen.y:10
+func square(n int) int {
+	return n * n
+}
+
+//line :20
+func cube(n int) int {
+	return n * n * n
+}
+
+//line main.go:13
+func escape() *int {
+	x := 42
+	return &x
+}
+
+//line calc.y:1:1
+func add(a, b int) int { return a + b }
+
+//line main.go:20
+func main() {
+	println(square(2), /*line calc.y:5:3*/ cube(3), *escape(), add(1, 2))
+}
@@ -0,0 +1,45 @@
+package main
+
+type node struct {
+	next *node
+	val  int
+}
+
+var head *node
+
+//go:noinline
+func sum(s []int) int {
+	t := 0
+	for i := 0; i < len(s); i++ {
+		t += s[i]
+	}
+	return t
+}
+
+//go:noinline
+func index(s []int, i int) int {
+	return s[i]
+}
+
+//go:noinline
+func copyPrefix(dst, src []byte, n int) {
+	for i := 0; i < n; i++ {
+		dst[i] = src[i]
+	}
+}
+
+//go:noinline
+func push(v int) {
+	head = &node{next: head, val: v}
+}
+
+func main() {
+	s := make([]int, 16)
+	for i := range s {
+		s[i] = i
+	}
+	buf := make([]byte, 8)
+	copyPrefix(buf, []byte("corpus!!"), 4)
+	push(sum(s))
+	println(index(s, 3), head.val, buf[0])
+}
@@ -0,0 +1,388 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Bolt is a backend storing values in a bbolt database file.
+//
+// Accesses are recorded in memory and written to the database in batches, so that
+// reading values does not require write transactions.
+type Bolt struct {
+	db *bbolt.DB
+
+	mu       sync.Mutex
+	accesses map[string]boltAccess // Accesses not yet written to the database.
+	flushMu  sync.Mutex            // Held while accesses are being written.
+}
+
+type boltAccess struct {
+	accessed time.Time
+	hits     int
+}
+
+// NewBolt opens the bbolt database file. Values of databases of older versions that lost
+// their expiry are given legacyTTL from now when migrated, they are kept if it is zero.
+func NewBolt(filename string, legacyTTL time.Duration) (*Bolt, error) {
+	db, err := bbolt.Open(filename, 0o660, &bbolt.Options{
+		NoFreelistSync: true,
+		FreelistType:   bbolt.FreelistMapType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open cache file: %w", err)
+	}
+
+	backend := &Bolt{db: db, accesses: map[string]boltAccess{}}
+	if err := backend.createBuckets(legacyTTL); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create buckets: %w", err)
+	}
+
+	return backend, nil
+}
+
+func (backend *Bolt) Get(key []byte) ([]byte, bool, error) {
+	var value []byte
+	now := time.Now()
+	err := backend.db.View(func(tx *bbolt.Tx) error {
+		if expiry, ok := decodeExpiry(tx.Bucket(expiryBucketName).Get(key)); ok && !expiry.After(now) {
+			return nil
+		}
+		b := tx.Bucket(bucketName)
+		// Value is only valid during the transaction.
+		if v := b.Get(key); v != nil {
+			value = bytes.Clone(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if value == nil {
+		return nil, false, nil
+	}
+
+	backend.mu.Lock()
+	a := backend.accesses[string(key)]
+	a.accessed = time.Now()
+	a.hits++
+	backend.accesses[string(key)] = a
+	backend.mu.Unlock()
+
+	return value, true, nil
+}
+
+func (backend *Bolt) Set(key, value []byte, expiry time.Time) (int, bool, error) {
+	backend.mu.Lock()
+	delete(backend.accesses, string(key))
+	backend.mu.Unlock()
+
+	replacedSize, replaced := 0, false
+	err := backend.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		if old := b.Get(key); old != nil {
+			replacedSize, replaced = len(old), true
+		}
+		if err := b.Put(key, value); err != nil {
+			return err
+		}
+		if err := tx.Bucket(accessBucketName).Put(key, encodeAccess(time.Now(), 0)); err != nil {
+			return err
+		}
+
+		return setExpiry(tx, key, expiry)
+	})
+	return replacedSize, replaced, err
+}
+
+func (backend *Bolt) Touch(key []byte, expiry time.Time) (bool, error) {
+	found := false
+	err := backend.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket(bucketName).Get(key) == nil {
+			return nil
+		}
+		if old, ok := decodeExpiry(tx.Bucket(expiryBucketName).Get(key)); ok && !old.After(time.Now()) {
+			return nil
+		}
+		found = true
+		return setExpiry(tx, key, expiry)
+	})
+	return found, err
+}
+
+// setExpiry replaces the expiry of the key in the expiry index.
+//
+// Expiry index is keyed by the expiry time in Unix nanoseconds followed by the key, so that
+// expired keys can be found by iterating from the start. Expiry times of keys are stored
+// separately to find and remove stale index records.
+func setExpiry(tx *bbolt.Tx, key []byte, expiry time.Time) error {
+	eb := tx.Bucket(expiryBucketName)
+	ib := tx.Bucket(expiryIndexBucketName)
+	if old, ok := decodeExpiry(eb.Get(key)); ok {
+		if err := ib.Delete(expiryIndexKey(old, key)); err != nil {
+			return err
+		}
+	}
+	if expiry.IsZero() {
+		return eb.Delete(key)
+	}
+	if err := ib.Put(expiryIndexKey(expiry, key), nil); err != nil {
+		return err
+	}
+	return eb.Put(key, encodeExpiry(expiry))
+}
+
+// remove removes the key with its metadata.
+func remove(tx *bbolt.Tx, key []byte) error {
+	if err := setExpiry(tx, key, time.Time{}); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketName).Delete(key); err != nil {
+		return err
+	}
+	return tx.Bucket(accessBucketName).Delete(key)
+}
+
+func expiryIndexKey(expiry time.Time, key []byte) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(expiry.UnixNano()))
+	copy(k[8:], key)
+	return k
+}
+
+func encodeExpiry(expiry time.Time) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(expiry.UnixNano()))
+	return buf[:]
+}
+
+func decodeExpiry(b []byte) (time.Time, bool) {
+	if len(b) < 8 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b))), true
+}
+
+func (backend *Bolt) createBuckets(legacyTTL time.Duration) error {
+	err := backend.db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketName); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(expiryIndexBucketName); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(expiryBucketName); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(accessBucketName); err != nil {
+			return err
+		}
+		return migrateLegacyTTL(tx, legacyTTL)
+	})
+	return err
+}
+
+// migrateLegacyTTL moves expiry times from the legacy TTL bucket, keyed by the expiry
+// time in Unix seconds, to the expiry index.
+//
+// Every value had an expiry in the legacy bucket, but records of values expiring in the same
+// second overwrote each other. Values left without a record are given ttl from now, so that
+// they do not become permanent.
+func migrateLegacyTTL(tx *bbolt.Tx, ttl time.Duration) error {
+	ttlb := tx.Bucket(legacyTTLBucketName)
+	if ttlb == nil {
+		return nil
+	}
+	b := tx.Bucket(bucketName)
+	// Records are ordered by expiry, so the latest one of the key wins.
+	err := ttlb.ForEach(func(k, v []byte) error {
+		if len(k) != 8 || b.Get(v) == nil {
+			return nil
+		}
+		expiry := time.Unix(int64(binary.BigEndian.Uint64(k)), 0)
+		return setExpiry(tx, bytes.Clone(v), expiry)
+	})
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		eb := tx.Bucket(expiryBucketName)
+		var lost [][]byte
+		err := b.ForEach(func(k, _ []byte) error {
+			if eb.Get(k) == nil {
+				lost = append(lost, bytes.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		expiry := time.Now().Add(ttl)
+		for _, k := range lost {
+			if err := setExpiry(tx, k, expiry); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.DeleteBucket(legacyTTLBucketName)
+}
+
+func (backend *Bolt) Delete(key []byte) error {
+	backend.mu.Lock()
+	delete(backend.accesses, string(key))
+	backend.mu.Unlock()
+
+	return backend.db.Update(func(tx *bbolt.Tx) error {
+		return remove(tx, key)
+	})
+}
+
+func (backend *Bolt) Entries(fn func(Entry) bool) error {
+	if err := backend.flushAccesses(); err != nil {
+		return err
+	}
+	var entries []Entry
+	err := backend.db.View(func(tx *bbolt.Tx) error {
+		ab := tx.Bucket(accessBucketName)
+		return tx.Bucket(bucketName).ForEach(func(k, v []byte) error {
+			e := Entry{Key: bytes.Clone(k), Size: len(v)}
+			e.Accessed, e.Hits = decodeAccess(ab.Get(k))
+			entries = append(entries, e)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// Callback is called outside of the transaction so that it can modify the database.
+	for _, e := range entries {
+		if !fn(e) {
+			break
+		}
+	}
+	return nil
+}
+
+func (backend *Bolt) Scan(after []byte, limit int) ([]Record, error) {
+	var records []Record
+	now := time.Now()
+	err := backend.db.View(func(tx *bbolt.Tx) error {
+		eb := tx.Bucket(expiryBucketName)
+		c := tx.Bucket(bucketName).Cursor()
+		k, v := c.Seek(after)
+		if k != nil && after != nil && bytes.Equal(k, after) {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(records) < limit; k, v = c.Next() {
+			expiry, ok := decodeExpiry(eb.Get(k))
+			if ok && !expiry.After(now) {
+				continue
+			}
+			records = append(records, Record{Key: bytes.Clone(k), Value: bytes.Clone(v), Expiry: expiry})
+		}
+		return nil
+	})
+	return records, err
+}
+
+// flushAccesses writes recorded accesses to the database.
+func (backend *Bolt) flushAccesses() error {
+	backend.flushMu.Lock()
+	defer backend.flushMu.Unlock()
+
+	backend.mu.Lock()
+	accesses := backend.accesses
+	backend.accesses = map[string]boltAccess{}
+	backend.mu.Unlock()
+	if len(accesses) == 0 {
+		return nil
+	}
+
+	return backend.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketName)
+		ab := tx.Bucket(accessBucketName)
+		for k, a := range accesses {
+			key := []byte(k)
+			if b.Get(key) == nil {
+				continue
+			}
+			_, hits := decodeAccess(ab.Get(key))
+			if err := ab.Put(key, encodeAccess(a.accessed, hits+a.hits)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func encodeAccess(accessed time.Time, hits int) []byte {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(accessed.UnixNano()))
+	binary.BigEndian.PutUint64(buf[8:], uint64(hits))
+	return buf[:]
+}
+
+func decodeAccess(b []byte) (time.Time, int) {
+	if len(b) < 16 {
+		return time.Time{}, 0
+	}
+	accessed := time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+	return accessed, int(binary.BigEndian.Uint64(b[8:]))
+}
+
+func (backend *Bolt) Cleanup(now time.Time) error {
+	nowKey := encodeExpiry(now)
+
+	var keysToDelete [][]byte
+
+	var err error
+
+	err = backend.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(expiryIndexBucketName).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], nowKey) < 0; k, _ = c.Next() {
+			keysToDelete = append(keysToDelete, bytes.Clone(k[8:]))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = backend.db.Batch(func(tx *bbolt.Tx) error {
+		eb := tx.Bucket(expiryBucketName)
+		for _, k := range keysToDelete {
+			// The key could have been touched or set again since.
+			if expiry, ok := decodeExpiry(eb.Get(k)); !ok || !expiry.Before(now) {
+				continue
+			}
+			if err := remove(tx, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return backend.flushAccesses()
+}
+
+func (backend *Bolt) Close() error {
+	_ = backend.flushAccesses()
+	return backend.db.Close()
+}
+
+var (
+	bucketName            = []byte("c")
+	expiryIndexBucketName = []byte("x")
+	expiryBucketName      = []byte("e")
+	accessBucketName      = []byte("a")
+	legacyTTLBucketName   = []byte("t")
+)
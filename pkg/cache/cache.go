@@ -2,54 +2,142 @@ package cache
 
 import (
 	"bytes"
-	"encoding/binary"
 	"encoding/gob"
-	"fmt"
+	"errors"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"go.etcd.io/bbolt"
 )
 
 type Key interface {
 	Hash() []byte
 }
 
+// Backend stores encoded values by key.
+type Backend interface {
+	// Get returns the value stored by the key and records the access.
+	Get(key []byte) ([]byte, bool, error)
+	// Set stores the value by the key. The value expires at expiry, unless it is zero.
+	// Returns the size of the replaced value and whether there was one.
+	Set(key, value []byte, expiry time.Time) (int, bool, error)
+	// Touch replaces the expiry of the value stored by the key, reporting whether it is found.
+	Touch(key []byte, expiry time.Time) (bool, error)
+	// Delete removes the value stored by the key.
+	Delete(key []byte) error
+	// Entries calls fn for every stored value until it returns false.
+	Entries(fn func(Entry) bool) error
+	// Scan returns up to limit values with keys greater than after, in the order of keys.
+	// Expired values are skipped, scanning does not count as access.
+	Scan(after []byte, limit int) ([]Record, error)
+	// Cleanup removes values expired before now.
+	Cleanup(now time.Time) error
+	Close() error
+}
+
+// Entry describes a stored value.
+type Entry struct {
+	Key  []byte
+	Size int
+	// Time of the last access, or of the time the value was set.
+	Accessed time.Time
+	// Number of accesses since the value was set.
+	Hits int
+}
+
+// Record is a stored value with its key.
+type Record struct {
+	Key   []byte
+	Value []byte
+	// Zero if the value does not expire.
+	Expiry time.Time
+}
+
+// Item is a value of the cache with the hash of its key.
+type Item[V any] struct {
+	Key   []byte
+	Value V
+	// Zero if the value does not expire.
+	Expiry time.Time
+}
+
+// Eviction is a policy of choosing values to evict when the cache exceeds its limits.
+type Eviction string
+
+const (
+	EvictLRU Eviction = "lru" // Evict least recently used values first.
+	EvictLFU Eviction = "lfu" // Evict least frequently used values first.
+)
+
+// Stats holds cache usage statistics.
+type Stats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	HitRate   float64 `json:"hitRate"`
+	Evictions int64   `json:"evictions"`
+	Entries   int     `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+}
+
 type Cache[K Key, V any] struct {
-	db *bbolt.DB
+	backend Backend
 
 	cleanupInterval time.Duration
+	maxEntries      int
+	maxSize         int64
+	eviction        Eviction
+	slidingTTL      time.Duration
+	version         string
+	typeVersioning  bool
+	legacyValues    bool
+	compression     Compression
+	envelope        envelope
 
-	doneCh chan struct{}
-	wg     sync.WaitGroup
+	hits, misses, evictions atomic.Int64
+	// Estimated number and size of values, updated on every Set and recalculated on eviction.
+	entries, size atomic.Int64
+
+	evictCh chan struct{}
+	doneCh  chan struct{}
+	wg      sync.WaitGroup
 }
 
+// New returns a cache stored in the bbolt database file.
 func New[K Key, V any](filename string, opts ...Option[K, V]) (*Cache[K, V], error) {
-	db, err := bbolt.Open(filename, 0o660, &bbolt.Options{
-		NoFreelistSync: true,
-		FreelistType:   bbolt.FreelistMapType,
-	})
+	backend, err := NewBolt(filename, 0)
 	if err != nil {
-		return nil, fmt.Errorf("open cache file: %w", err)
+		return nil, err
 	}
+	return NewWithBackend(backend, opts...), nil
+}
 
+// NewWithBackend returns a cache stored in the backend.
+func NewWithBackend[K Key, V any](backend Backend, opts ...Option[K, V]) *Cache[K, V] {
 	cache := &Cache[K, V]{
-		db:              db,
+		backend:         backend,
 		cleanupInterval: 1 * time.Minute,
+		eviction:        EvictLRU,
+		typeVersioning:  true,
+		evictCh:         make(chan struct{}, 1),
 		doneCh:          make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(cache)
 	}
-
-	if err := cache.createBuckets(); err != nil {
-		return nil, fmt.Errorf("create buckets: %w", err)
+	var valueType reflect.Type
+	if cache.typeVersioning {
+		valueType = reflect.TypeFor[V]()
 	}
+	cache.envelope = newEnvelope(cache.version, valueType, cache.compression, cache.legacyValues)
 
+	if cache.bounded() {
+		_ = cache.evict()
+	}
 	cache.startCleanup(cache.cleanupInterval)
 
-	return cache, nil
+	return cache
 }
 
 type Option[K Key, V any] func(*Cache[K, V])
@@ -60,75 +148,324 @@ func WithCleanupInterval[K Key, V any](v time.Duration) Option[K, V] {
 	}
 }
 
+// WithMaxEntries limits the number of values in the cache, unbounded if zero.
+func WithMaxEntries[K Key, V any](v int) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.maxEntries = v
+	}
+}
+
+// WithMaxSize limits the total size of encoded values in the cache in bytes, unbounded if zero.
+func WithMaxSize[K Key, V any](v int64) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.maxSize = v
+	}
+}
+
+// WithEviction sets the eviction policy used when the cache exceeds its limits, LRU by default.
+func WithEviction[K Key, V any](v Eviction) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.eviction = v
+	}
+}
+
+// WithSlidingExpiration makes Get extend the expiry of found values to ttl from now.
+func WithSlidingExpiration[K Key, V any](ttl time.Duration) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.slidingTTL = ttl
+	}
+}
+
+// WithVersion sets the version of stored values. Values stored with a different version,
+// or with a different structure of the value type, are treated as missing and removed.
+func WithVersion[K Key, V any](v string) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.version = v
+	}
+}
+
+// WithTypeVersioning sets whether values stored before the structure of the value type
+// has changed are discarded, enabled by default. Disable it for values that must outlive
+// changes of their type, which then have to remain decodable from older values.
+// Concrete types of values of interface fields are not taken into account.
+func WithTypeVersioning[K Key, V any](v bool) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.typeVersioning = v
+	}
+}
+
+// WithLegacyValues sets whether values stored before values were versioned are kept and
+// decoded as they are, disabled by default. Enable it for values that must outlive upgrades,
+// such as values of caches stored without an expiry.
+func WithLegacyValues[K Key, V any](v bool) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.legacyValues = v
+	}
+}
+
+// WithCompression sets the compression algorithm of large values, uncompressed by default.
+func WithCompression[K Key, V any](v Compression) Option[K, V] {
+	return func(cache *Cache[K, V]) {
+		cache.compression = v
+	}
+}
+
 func (cache *Cache[K, V]) Get(k K, v *V) (bool, error) {
-	found := false
-	err := cache.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketName)
-		key := k.Hash()
-		value := b.Get(key)
-		found = value != nil
-		if found {
-			if err := unmarshal(value, v); err != nil {
-				return err
+	key := k.Hash()
+	value, found, err := cache.backend.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		cache.misses.Add(1)
+		return false, nil
+	}
+	value, err = cache.envelope.open(value)
+	if errors.Is(err, errStaleVersion) {
+		cache.misses.Add(1)
+		return false, cache.backend.Delete(key)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := unmarshal(value, v); err != nil {
+		return false, err
+	}
+	cache.hits.Add(1)
+	if cache.slidingTTL > 0 {
+		if _, err := cache.backend.Touch(key, time.Now().Add(cache.slidingTTL)); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Touch sets the expiry of the value stored by the key to ttl from now, or removes it if ttl
+// is zero, reporting whether the value is found.
+func (cache *Cache[K, V]) Touch(k Key, ttl time.Duration) (bool, error) {
+	var expiry time.Time
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl)
+	}
+	return cache.backend.Touch(k.Hash(), expiry)
+}
+
+// Delete removes the value stored by the key.
+func (cache *Cache[K, V]) Delete(k Key) error {
+	return cache.backend.Delete(k.Hash())
+}
+
+func (cache *Cache[K, V]) Set(k Key, v V, ttl time.Duration) error {
+	value, err := marshal(v)
+	if err != nil {
+		return err
+	}
+	value, err = cache.envelope.seal(value)
+	if err != nil {
+		return err
+	}
+	var expiry time.Time
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl)
+	}
+	replacedSize, replaced, err := cache.backend.Set(k.Hash(), value, expiry)
+	if err != nil {
+		return err
+	}
+	if cache.bounded() {
+		entries := cache.entries.Load()
+		if !replaced {
+			entries = cache.entries.Add(1)
+		}
+		size := cache.size.Add(int64(len(value) - replacedSize))
+		if cache.exceeds(int(entries), size) {
+			select {
+			case cache.evictCh <- struct{}{}:
+			default:
 			}
 		}
-		return nil
+	}
+	return nil
+}
+
+// DeleteFunc removes values with keys for which del returns true, returning the number of removed values.
+func (cache *Cache[K, V]) DeleteFunc(del func(key []byte) bool) (int, error) {
+	var keys [][]byte
+	err := cache.backend.Entries(func(e Entry) bool {
+		if del(e.Key) {
+			keys = append(keys, e.Key)
+		}
+		return true
 	})
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	return found, nil
+	for i, key := range keys {
+		if err := cache.backend.Delete(key); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
 }
 
-func (cache *Cache[K, V]) Set(k Key, v V, ttl time.Duration) error {
-	err := cache.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketName)
-		key := k.Hash()
-		value, err := marshal(v)
+// Purge removes all values, returning the number of removed values.
+func (cache *Cache[K, V]) Purge() (int, error) {
+	return cache.DeleteFunc(func([]byte) bool { return true })
+}
+
+// Number of values read from the backend at once by Scan.
+const scanBatchSize = 256
+
+// Scan calls fn for values of the cache in the order of key hashes until it returns false.
+// Values are read from the backend in batches, so fn may modify the cache. Values stored by
+// a different version of the cache are skipped.
+func (cache *Cache[K, V]) Scan(fn func(Item[V]) bool) error {
+	var after []byte
+	for {
+		records, err := cache.backend.Scan(after, scanBatchSize)
 		if err != nil {
 			return err
 		}
-		if err := b.Put(key[:], value); err != nil {
-			return err
+		for _, r := range records {
+			value, err := cache.envelope.open(r.Value)
+			if errors.Is(err, errStaleVersion) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			item := Item[V]{Key: r.Key, Expiry: r.Expiry}
+			if err := unmarshal(value, &item.Value); err != nil {
+				return err
+			}
+			if !fn(item) {
+				return nil
+			}
 		}
+		if len(records) < scanBatchSize {
+			return nil
+		}
+		after = records[len(records)-1].Key
+	}
+}
 
-		if ttl > 0 {
-			ttlb := tx.Bucket(ttlBucketName)
-			expiry := time.Now().Add(ttl)
-			var ttlKey [8]byte
-			binary.BigEndian.PutUint64(ttlKey[:], uint64(expiry.Unix()))
-			if err := ttlb.Put(ttlKey[:], key[:]); err != nil {
+// removeStale removes values stored by a different version of the cache, which are
+// otherwise only removed when read.
+func (cache *Cache[K, V]) removeStale() error {
+	var after []byte
+	for {
+		records, err := cache.backend.Scan(after, scanBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			if !cache.envelope.stale(r.Value) {
+				continue
+			}
+			if err := cache.backend.Delete(r.Key); err != nil {
 				return err
 			}
 		}
+		if len(records) < scanBatchSize {
+			return nil
+		}
+		after = records[len(records)-1].Key
+	}
+}
 
-		return nil
+// Stats returns usage statistics of the cache.
+func (cache *Cache[K, V]) Stats() (Stats, error) {
+	stats := Stats{
+		Hits:      cache.hits.Load(),
+		Misses:    cache.misses.Load(),
+		Evictions: cache.evictions.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	err := cache.backend.Entries(func(e Entry) bool {
+		stats.Entries++
+		stats.Bytes += int64(e.Size)
+		return true
 	})
-	return err
+	return stats, err
 }
 
-func (cache *Cache[K, V]) createBuckets() error {
-	err := cache.db.Update(func(tx *bbolt.Tx) error {
-		if _, err := tx.CreateBucketIfNotExists(bucketName); err != nil {
-			return err
+func (cache *Cache[K, V]) bounded() bool {
+	return cache.maxEntries > 0 || cache.maxSize > 0
+}
+
+func (cache *Cache[K, V]) exceeds(entries int, size int64) bool {
+	return (cache.maxEntries > 0 && entries > cache.maxEntries) ||
+		(cache.maxSize > 0 && size > cache.maxSize)
+}
+
+// evict removes values according to the eviction policy until the cache fits its limits.
+func (cache *Cache[K, V]) evict() error {
+	var entries []Entry
+	var size int64
+	err := cache.backend.Entries(func(e Entry) bool {
+		entries = append(entries, e)
+		size += int64(e.Size)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if cache.exceeds(len(entries), size) {
+		sortForEviction(entries, cache.eviction)
+		for len(entries) > 0 && cache.exceeds(len(entries), size) {
+			e := entries[0]
+			if err := cache.backend.Delete(e.Key); err != nil {
+				return err
+			}
+			entries = entries[1:]
+			size -= int64(e.Size)
+			cache.evictions.Add(1)
 		}
-		if _, err := tx.CreateBucketIfNotExists(ttlBucketName); err != nil {
-			return err
+	}
+
+	cache.entries.Store(int64(len(entries)))
+	cache.size.Store(size)
+	return nil
+}
+
+// sortForEviction orders entries so that the ones to evict first come first.
+func sortForEviction(entries []Entry, eviction Eviction) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if eviction == EvictLFU && a.Hits != b.Hits {
+			return a.Hits < b.Hits
 		}
-		return nil
+		return a.Accessed.Before(b.Accessed)
 	})
-	return err
 }
 
+// Interval of removing values stored by a different version of the cache during cleanup.
+// They are mostly left by previous versions, so they are also removed on start.
+const staleCleanupInterval = time.Hour
+
 func (cache *Cache[K, V]) startCleanup(interval time.Duration) {
 	cache.wg.Add(1)
 	go func() {
 		defer cache.wg.Done()
+		_ = cache.removeStale()
+		staleRemoved := time.Now()
 		ticker := time.NewTicker(interval)
 		for {
 			select {
 			case <-ticker.C:
-				_ = cache.cleanup()
+				_ = cache.backend.Cleanup(time.Now())
+				if time.Since(staleRemoved) >= staleCleanupInterval {
+					_ = cache.removeStale()
+					staleRemoved = time.Now()
+				}
+				if cache.bounded() {
+					_ = cache.evict()
+				}
+			case <-cache.evictCh:
+				_ = cache.evict()
 			case <-cache.doneCh:
 				return
 			}
@@ -136,66 +473,12 @@ func (cache *Cache[K, V]) startCleanup(interval time.Duration) {
 	}()
 }
 
-func (cache *Cache[K, V]) cleanup() error {
-	var nowKey [8]byte
-	binary.BigEndian.PutUint64(nowKey[:], uint64(time.Now().Unix()))
-
-	var keysToDelete [][]byte
-	var ttlKeysToDelete [][]byte
-
-	var err error
-
-	err = cache.db.View(func(tx *bbolt.Tx) error {
-		ttlb := tx.Bucket(ttlBucketName)
-		c := ttlb.Cursor()
-		k, v := c.First()
-		for k != nil {
-			if bytes.Compare(k, nowKey[:]) >= 0 {
-				break
-			}
-			keysToDelete = append(keysToDelete, v)
-			ttlKeysToDelete = append(ttlKeysToDelete, k)
-			k, v = c.Next()
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-
-	err = cache.db.Batch(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(bucketName)
-		for _, k := range keysToDelete {
-			if err := b.Delete(k); err != nil {
-				return err
-			}
-		}
-		ttlb := tx.Bucket(ttlBucketName)
-		for _, k := range ttlKeysToDelete {
-			if err := ttlb.Delete(k); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (cache *Cache[K, V]) Close() {
 	close(cache.doneCh)
 	cache.wg.Wait()
-	_ = cache.db.Close()
+	_ = cache.backend.Close()
 }
 
-var (
-	bucketName    = []byte("c")
-	ttlBucketName = []byte("t")
-)
-
 func marshal(e any) ([]byte, error) {
 	b := &bytes.Buffer{}
 	w := gob.NewEncoder(b)
@@ -1,23 +1,35 @@
 package cache_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"go.etcd.io/bbolt"
+
 	"github.com/w1ck3dg0ph3r/goce/pkg/cache"
 )
 
 func TestCache(t *testing.T) {
-	tmpdir := t.TempDir()
-	c, err := cache.New[key, value](
-		filepath.Join(tmpdir, "cache.db"),
-		cache.WithCleanupInterval[key, value](500*time.Millisecond),
-	)
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			c := cache.NewWithBackend(backend, cache.WithCleanupInterval[key, value](500*time.Millisecond))
+			t.Cleanup(c.Close)
+			testCache(t, c)
+		})
 	}
+}
+
+func testCache(t *testing.T, c *cache.Cache[key, value]) {
 
 	t.Run("set", func(t *testing.T) {
 		err := c.Set(key("aaa"), value{"foo", []string{"one", "two", "three"}}, 0)
@@ -85,6 +97,501 @@ func TestCache(t *testing.T) {
 	})
 }
 
+func TestExpiry(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			expiry := now.Add(time.Hour)
+			set := func(k string, expiry time.Time) {
+				t.Helper()
+				if _, _, err := backend.Set([]byte(k), []byte(k), expiry); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+			// Expiring at the same time.
+			set("aaa", expiry)
+			set("bbb", expiry)
+			// Set again without expiry.
+			set("ccc", expiry)
+			set("ccc", time.Time{})
+			// Touched to expire later.
+			set("ddd", expiry)
+			if ok, err := backend.Touch([]byte("ddd"), expiry.Add(time.Hour)); !ok || err != nil {
+				t.Errorf("expected to touch ddd, got %v, %v", ok, err)
+			}
+			// Deleted.
+			set("eee", time.Time{})
+			if err := backend.Delete([]byte("eee")); err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+
+			if ok, _ := backend.Touch([]byte("fff"), expiry); ok {
+				t.Errorf("expected to not touch missing key")
+			}
+
+			if err := backend.Cleanup(expiry.Add(time.Second)); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var keys []string
+			_ = backend.Entries(func(e cache.Entry) bool {
+				keys = append(keys, string(e.Key))
+				return true
+			})
+			sort.Strings(keys)
+			if want := []string{"ccc", "ddd"}; !reflect.DeepEqual(keys, want) {
+				t.Errorf("expected keys %v, got %v", want, keys)
+			}
+		})
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	c := cache.NewWithBackend(cache.NewMemory(0, 0), cache.WithSlidingExpiration[key, value](time.Hour))
+	t.Cleanup(c.Close)
+
+	if err := c.Set(key("aaa"), value{Name: "foo"}, 200*time.Millisecond); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var v value
+	if ok, _ := c.Get(key("aaa"), &v); !ok {
+		t.Fatalf("expected to find key")
+	}
+	time.Sleep(300 * time.Millisecond)
+	if ok, _ := c.Get(key("aaa"), &v); !ok {
+		t.Errorf("expected key expiry to be extended")
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			c := cache.NewWithBackend[key, value](backend)
+			t.Cleanup(c.Close)
+
+			for _, k := range []key{"a1", "a2", "b1"} {
+				if err := c.Set(k, value{Name: string(k)}, time.Hour); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+			n, err := c.DeleteFunc(func(key []byte) bool { return strings.HasPrefix(string(key), "a") })
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if n != 2 {
+				t.Errorf("expected 2 deleted values, got %d", n)
+			}
+			var v value
+			for k, want := range map[key]bool{"a1": false, "a2": false, "b1": true} {
+				if ok, _ := c.Get(k, &v); ok != want {
+					t.Errorf("expected key %q to be found: %v, got %v", k, want, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			c := cache.NewWithBackend[key, value](backend)
+			t.Cleanup(c.Close)
+
+			// More values than are read in a single batch.
+			const n = 300
+			for i := range n {
+				k := key(fmt.Sprintf("k%03d", i))
+				if err := c.Set(k, value{Name: string(k)}, time.Hour); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+			if err := c.Set(key("expired"), value{Name: "expired"}, time.Millisecond); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			var names []string
+			err := c.Scan(func(item cache.Item[value]) bool {
+				if string(item.Key) != item.Value.Name {
+					t.Errorf("expected value %q, got %q", item.Key, item.Value.Name)
+				}
+				if item.Expiry.IsZero() {
+					t.Errorf("expected expiry of %q", item.Key)
+				}
+				names = append(names, item.Value.Name)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(names) != n {
+				t.Fatalf("expected %d values, got %d", n, len(names))
+			}
+			if !sort.StringsAreSorted(names) {
+				t.Errorf("expected values in the order of keys")
+			}
+
+			count := 0
+			_ = c.Scan(func(cache.Item[value]) bool {
+				count++
+				return count < 5
+			})
+			if count != 5 {
+				t.Errorf("expected scan to stop after 5 values, got %d", count)
+			}
+		})
+	}
+}
+
+func TestBoltLegacyTTL(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.db")
+	db, err := bbolt.Open(filename, 0o660, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expiry := time.Now().Add(time.Hour)
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, _ := tx.CreateBucket([]byte("c"))
+		ttlb, _ := tx.CreateBucket([]byte("t"))
+		// Records of aaa and bbb expiring in the same second collided, only bbb's is left.
+		_ = b.Put([]byte("aaa"), []byte("aaa"))
+		_ = b.Put([]byte("bbb"), []byte("bbb"))
+		var ttlKey [8]byte
+		binary.BigEndian.PutUint64(ttlKey[:], uint64(expiry.Unix()))
+		return ttlb.Put(ttlKey[:], []byte("bbb"))
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_ = db.Close()
+
+	backend, err := cache.NewBolt(filename, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer func() { _ = backend.Close() }()
+
+	if err := backend.Cleanup(expiry.Add(time.Second)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok, _ := backend.Get([]byte("bbb")); ok {
+		t.Errorf("expected bbb to expire")
+	}
+	if _, ok, _ := backend.Get([]byte("aaa")); !ok {
+		t.Errorf("expected to find aaa with the legacy ttl")
+	}
+	if err := backend.Cleanup(time.Now().Add(2*time.Hour + time.Minute)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok, _ := backend.Get([]byte("aaa")); ok {
+		t.Errorf("expected aaa to expire after the legacy ttl")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	backend := cache.NewMemory(0, 0)
+	v1 := cache.NewWithBackend(backend, cache.WithVersion[key, value]("1"))
+	t.Cleanup(v1.Close)
+
+	if err := v1.Set(key("aaa"), value{Name: "foo"}, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// Value stored before values had an envelope.
+	if _, _, err := backend.Set([]byte("bbb"), []byte("\x0cgarbage"), time.Time{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var v value
+	if ok, _ := v1.Get(key("aaa"), &v); !ok || v.Name != "foo" {
+		t.Errorf("expected to find aaa, got %v", v)
+	}
+	// Stale values are removed on start of the cache, unless they are read before.
+	v2 := cache.NewWithBackend(backend, cache.WithVersion[key, value]("2"))
+	t.Cleanup(v2.Close)
+	for _, k := range []key{"aaa", "bbb"} {
+		if ok, err := v2.Get(k, &v); ok || err != nil {
+			t.Errorf("expected stale %s to not be found, got %v, %v", k, ok, err)
+		}
+		if _, ok, _ := backend.Get([]byte(k)); ok {
+			t.Errorf("expected stale %s to be removed", k)
+		}
+	}
+}
+
+func TestRemoveStale(t *testing.T) {
+	backend := cache.NewMemory(0, 0)
+	v1 := cache.NewWithBackend(backend, cache.WithVersion[key, value]("1"))
+	for _, k := range []key{"aaa", "bbb"} {
+		if err := v1.Set(k, value{Name: string(k)}, 0); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	v1.Close()
+
+	v2 := cache.NewWithBackend(backend, cache.WithVersion[key, value]("2"))
+	t.Cleanup(v2.Close)
+	waitFor(t, func() bool {
+		n := 0
+		_ = backend.Entries(func(cache.Entry) bool { n++; return true })
+		return n == 0
+	})
+}
+
+func TestTypeVersioning(t *testing.T) {
+	type valueV2 struct {
+		Name   string
+		Values []string
+		Extra  int
+	}
+
+	for _, versioning := range []bool{true, false} {
+		t.Run(fmt.Sprint(versioning), func(t *testing.T) {
+			backend := cache.NewMemory(0, 0)
+			v1 := cache.NewWithBackend(backend, cache.WithTypeVersioning[key, value](versioning))
+			t.Cleanup(v1.Close)
+			v2 := cache.NewWithBackend(backend, cache.WithTypeVersioning[key, valueV2](versioning))
+			t.Cleanup(v2.Close)
+
+			if err := v1.Set(key("aaa"), value{Name: "foo"}, 0); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var v valueV2
+			if ok, err := v2.Get(key("aaa"), &v); err != nil || ok == versioning {
+				t.Errorf("expected value to be found: %v, got %v, %v", !versioning, ok, err)
+			}
+			if !versioning && v.Name != "foo" {
+				t.Errorf("expected value to be decoded, got %v", v)
+			}
+		})
+	}
+}
+
+func TestLegacyValues(t *testing.T) {
+	backend := cache.NewMemory(0, 0)
+	c := cache.NewWithBackend(backend, cache.WithLegacyValues[key, value](true))
+	t.Cleanup(c.Close)
+
+	// Value stored before values had an envelope.
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(value{Name: "foo"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, _, err := backend.Set([]byte("aaa"), legacy.Bytes(), time.Time{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var v value
+	if ok, err := c.Get(key("aaa"), &v); !ok || err != nil || v.Name != "foo" {
+		t.Errorf("expected to find legacy aaa, got %v, %v, %v", v, ok, err)
+	}
+	var scanned []string
+	if err := c.Scan(func(item cache.Item[value]) bool {
+		scanned = append(scanned, item.Value.Name)
+		return true
+	}); err != nil || !reflect.DeepEqual(scanned, []string{"foo"}) {
+		t.Errorf("expected to scan legacy aaa, got %v, %v", scanned, err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	large := value{Name: strings.Repeat("MOVQ AX, BX\n", 1000)}
+	for _, compression := range []cache.Compression{cache.CompressionNone, cache.CompressionZstd, cache.CompressionBrotli} {
+		t.Run(string(compression), func(t *testing.T) {
+			backend := cache.NewMemory(0, 0)
+			c := cache.NewWithBackend(backend, cache.WithCompression[key, value](compression))
+			t.Cleanup(c.Close)
+
+			if err := c.Set(key("aaa"), large, 0); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			var v value
+			if ok, err := c.Get(key("aaa"), &v); !ok || err != nil || !reflect.DeepEqual(v, large) {
+				t.Errorf("expected to get the value back, got %v, %v", ok, err)
+			}
+			stored, _, _ := backend.Get([]byte("aaa"))
+			if compressed := len(stored) < len(large.Name); compressed != (compression != cache.CompressionNone) {
+				t.Errorf("unexpected stored size %d of %d bytes", len(stored), len(large.Name))
+			}
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	for _, eviction := range []cache.Eviction{cache.EvictLRU, cache.EvictLFU} {
+		for name, backend := range backends(t) {
+			t.Run(string(eviction)+"/"+name, func(t *testing.T) {
+				c := cache.NewWithBackend(backend,
+					cache.WithMaxEntries[key, value](2),
+					cache.WithEviction[key, value](eviction),
+				)
+				t.Cleanup(c.Close)
+
+				var v value
+				for _, k := range []key{"aaa", "bbb"} {
+					if err := c.Set(k, value{Name: string(k)}, 0); err != nil {
+						t.Fatalf("expected no error, got %v", err)
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				// aaa is both more recently and more frequently used than bbb.
+				for range 2 {
+					if ok, _ := c.Get(key("aaa"), &v); !ok {
+						t.Fatalf("expected to find aaa")
+					}
+				}
+				if err := c.Set(key("ccc"), value{Name: "ccc"}, 0); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+
+				waitFor(t, func() bool {
+					stats, _ := c.Stats()
+					return stats.Evictions == 1
+				})
+				if ok, _ := c.Get(key("bbb"), &v); ok {
+					t.Errorf("expected bbb to be evicted")
+				}
+				if ok, _ := c.Get(key("aaa"), &v); !ok {
+					t.Errorf("expected to find aaa")
+				}
+			})
+		}
+	}
+}
+
+func TestOverwriteAtCapacity(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			counting := &scanCountingBackend{Backend: backend}
+			c := cache.NewWithBackend[key, value](counting, cache.WithMaxEntries[key, value](2))
+			t.Cleanup(c.Close)
+
+			for _, k := range []key{"aaa", "bbb"} {
+				if err := c.Set(k, value{Name: string(k)}, 0); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+			scans := counting.scans.Load()
+			for i := range 100 {
+				if err := c.Set(key("aaa"), value{Name: fmt.Sprint(i)}, 0); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			if n := counting.scans.Load() - scans; n != 0 {
+				t.Errorf("expected no eviction scans for overwritten values, got %d", n)
+			}
+			if stats, _ := c.Stats(); stats.Evictions != 0 || stats.Entries != 2 {
+				t.Errorf("expected 2 entries and no evictions, got %+v", stats)
+			}
+		})
+	}
+}
+
+// scanCountingBackend counts scans of all entries of the backend.
+type scanCountingBackend struct {
+	cache.Backend
+	scans atomic.Int64
+}
+
+func (b *scanCountingBackend) Entries(fn func(cache.Entry) bool) error {
+	b.scans.Add(1)
+	return b.Backend.Entries(fn)
+}
+
+func TestStats(t *testing.T) {
+	c := cache.NewWithBackend(cache.NewMemory(0, 0), cache.WithMaxSize[key, value](1<<20))
+	t.Cleanup(c.Close)
+
+	var v value
+	if err := c.Set(key("aaa"), value{Name: "foo"}, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, _ = c.Get(key("aaa"), &v)
+	_, _ = c.Get(key("aaa"), &v)
+	_, _ = c.Get(key("bbb"), &v)
+	_, _ = c.Get(key("ccc"), &v)
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stats.Hits != 2 || stats.Misses != 2 || stats.HitRate != 0.5 {
+		t.Errorf("expected 2 hits and 2 misses, got %+v", stats)
+	}
+	if stats.Entries != 1 || stats.Bytes == 0 {
+		t.Errorf("expected 1 entry of non-zero size, got %+v", stats)
+	}
+}
+
+func TestMemoryEviction(t *testing.T) {
+	backend := cache.NewMemory(2, 0)
+	for _, k := range []string{"aaa", "bbb"} {
+		if _, _, err := backend.Set([]byte(k), []byte(k), time.Time{}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if _, ok, _ := backend.Get([]byte("aaa")); !ok {
+		t.Errorf("expected to find aaa")
+	}
+	if _, _, err := backend.Set([]byte("ccc"), []byte("ccc"), time.Time{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for k, want := range map[string]bool{"aaa": true, "bbb": false, "ccc": true} {
+		if _, ok, _ := backend.Get([]byte(k)); ok != want {
+			t.Errorf("expected %s to be found: %v, got %v", k, want, ok)
+		}
+	}
+}
+
+func TestMemoryMaxSize(t *testing.T) {
+	backend := cache.NewMemory(0, 8)
+	for _, k := range []string{"aaa", "bbb"} {
+		if _, _, err := backend.Set([]byte(k), []byte(k), time.Time{}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	// Overwriting a value does not count its old size.
+	if _, _, err := backend.Set([]byte("aaa"), []byte("aaaa"), time.Time{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, _, err := backend.Set([]byte("ccc"), []byte("ccc"), time.Time{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for k, want := range map[string]bool{"aaa": true, "bbb": false, "ccc": true} {
+		if _, ok, _ := backend.Get([]byte(k)); ok != want {
+			t.Errorf("expected %s to be found: %v, got %v", k, want, ok)
+		}
+	}
+}
+
+func backends(t *testing.T) map[string]cache.Backend {
+	tmpdir := t.TempDir()
+	bolt, err := cache.NewBolt(filepath.Join(tmpdir, "cache.db"), 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	fs, err := cache.NewFS(filepath.Join(tmpdir, "cache"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return map[string]cache.Backend{
+		"bolt":   bolt,
+		"memory": cache.NewMemory(0, 0),
+		"fs":     fs,
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 type key string
 
 func (k key) Hash() []byte {
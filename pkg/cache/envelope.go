@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression algorithm of stored values.
+type Compression string
+
+const (
+	CompressionNone   Compression = "none"
+	CompressionZstd   Compression = "zstd"
+	CompressionBrotli Compression = "brotli"
+)
+
+// Values smaller than this are not compressed.
+const compressionThreshold = 1 << 10
+
+// Values are stored in an envelope:
+//
+//	magic [2]byte, format byte, version [8]byte, compression byte, payload []byte
+//
+// where version is a hash of the cache version and the value type, if it is versioned.
+var envelopeMagic = [2]byte{'g', 'c'}
+
+const (
+	envelopeFormat     = 1
+	envelopeHeaderSize = len(envelopeMagic) + 1 + 8 + 1
+)
+
+// Compression algorithm identifiers in the envelope.
+const (
+	envelopeUncompressed byte = iota
+	envelopeZstd
+	envelopeBrotli
+)
+
+// errStaleVersion is returned when a value was stored by a different version of the cache.
+var errStaleVersion = errors.New("stale value version")
+
+// envelope wraps encoded values with a version and compresses them.
+type envelope struct {
+	version     [8]byte
+	compression Compression
+	// Whether data that is not an envelope is taken as a payload stored before envelopes.
+	legacy bool
+}
+
+// newEnvelope returns an envelope of the version and value type, which is nil if values
+// are not versioned by their type.
+func newEnvelope(version string, valueType reflect.Type, compression Compression, legacy bool) envelope {
+	h := fnv.New64a()
+	_, _ = io.WriteString(h, version)
+	if valueType != nil {
+		_, _ = h.Write([]byte{0})
+		_, _ = io.WriteString(h, typeFingerprint(valueType))
+	}
+	var e envelope
+	copy(e.version[:], h.Sum(nil))
+	e.compression = compression
+	e.legacy = legacy
+	return e
+}
+
+func (e envelope) seal(payload []byte) ([]byte, error) {
+	algorithm := envelopeUncompressed
+	if len(payload) >= compressionThreshold {
+		switch e.compression {
+		case CompressionZstd:
+			algorithm, payload = envelopeZstd, zstdEncoder.EncodeAll(payload, nil)
+		case CompressionBrotli:
+			b := &bytes.Buffer{}
+			w := brotli.NewWriterLevel(b, brotli.DefaultCompression)
+			if _, err := w.Write(payload); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			algorithm, payload = envelopeBrotli, b.Bytes()
+		}
+	}
+
+	data := make([]byte, 0, envelopeHeaderSize+len(payload))
+	data = append(data, envelopeMagic[:]...)
+	data = append(data, envelopeFormat)
+	data = append(data, e.version[:]...)
+	data = append(data, algorithm)
+	return append(data, payload...), nil
+}
+
+// stale reports whether the data was sealed by a different version, see open.
+func (e envelope) stale(data []byte) bool {
+	if len(data) < envelopeHeaderSize || !bytes.Equal(data[:2], envelopeMagic[:]) || data[2] != envelopeFormat {
+		return !e.legacy
+	}
+	return !bytes.Equal(data[3:11], e.version[:])
+}
+
+// open returns the payload of the envelope, or errStaleVersion if it was sealed
+// by a different version or is not an envelope at all, unless legacy values are kept.
+func (e envelope) open(data []byte) ([]byte, error) {
+	if e.stale(data) {
+		return nil, errStaleVersion
+	}
+	if len(data) < envelopeHeaderSize || !bytes.Equal(data[:2], envelopeMagic[:]) || data[2] != envelopeFormat {
+		// Legacy value.
+		return data, nil
+	}
+	payload := data[envelopeHeaderSize:]
+	switch data[11] {
+	case envelopeUncompressed:
+		return payload, nil
+	case envelopeZstd:
+		return zstdDecoder.DecodeAll(payload, nil)
+	case envelopeBrotli:
+		return io.ReadAll(brotli.NewReader(bytes.NewReader(payload)))
+	}
+	return nil, fmt.Errorf("unknown compression %d", data[11])
+}
+
+// Encoder and decoder are safe for concurrent use with EncodeAll and DecodeAll.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// typeFingerprint describes the structure of the type, so that values stored
+// before the type has changed are not decoded.
+//
+// Only the static type is described: changes of concrete types stored in interface fields
+// are not detected, so a different version has to be set with [WithVersion] for them.
+func typeFingerprint(t reflect.Type) string {
+	var sb strings.Builder
+	describeType(&sb, t, map[reflect.Type]bool{})
+	return sb.String()
+}
+
+func describeType(sb *strings.Builder, t reflect.Type, seen map[reflect.Type]bool) {
+	switch t.Kind() {
+	case reflect.Struct:
+		if seen[t] {
+			sb.WriteString(t.String())
+			return
+		}
+		seen[t] = true
+		sb.WriteString("struct{")
+		for i := range t.NumField() {
+			f := t.Field(i)
+			sb.WriteString(f.Name)
+			sb.WriteByte(' ')
+			describeType(sb, f.Type, seen)
+			sb.WriteByte(';')
+		}
+		sb.WriteByte('}')
+	case reflect.Pointer:
+		sb.WriteByte('*')
+		describeType(sb, t.Elem(), seen)
+	case reflect.Slice:
+		sb.WriteString("[]")
+		describeType(sb, t.Elem(), seen)
+	case reflect.Array:
+		fmt.Fprintf(sb, "[%d]", t.Len())
+		describeType(sb, t.Elem(), seen)
+	case reflect.Map:
+		sb.WriteString("map[")
+		describeType(sb, t.Key(), seen)
+		sb.WriteByte(']')
+		describeType(sb, t.Elem(), seen)
+	default:
+		sb.WriteString(t.String())
+	}
+}
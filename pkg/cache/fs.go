@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is a backend storing values in files named by their keys, so that several
+// instances can share a directory.
+//
+// Each file starts with the expiry time in Unix nanoseconds, zero if the value does not expire.
+// Modification time of the file is updated on access, numbers of accesses are only kept in memory.
+type FS struct {
+	dir string
+
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+const fsHeaderSize = 8
+
+// NewFS returns a filesystem backend storing values in the directory, creating it if necessary.
+func NewFS(dir string) (*FS, error) {
+	if err := os.MkdirAll(dir, 0o770); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+	return &FS{dir: dir, hits: map[string]int{}}, nil
+}
+
+// path returns the file of the key, spread over subdirectories by the first byte of the key.
+func (backend *FS) path(key []byte) string {
+	name := hex.EncodeToString(key)
+	if len(name) <= 2 {
+		return filepath.Join(backend.dir, name)
+	}
+	return filepath.Join(backend.dir, name[:2], name[2:])
+}
+
+func (backend *FS) Get(key []byte) ([]byte, bool, error) {
+	data, err := os.ReadFile(backend.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) < fsHeaderSize {
+		return nil, false, fmt.Errorf("corrupted cache file %x", key)
+	}
+	now := time.Now()
+	if expired(data, now) {
+		return nil, false, nil
+	}
+	_ = os.Chtimes(backend.path(key), now, now)
+	backend.mu.Lock()
+	backend.hits[string(key)]++
+	backend.mu.Unlock()
+	return data[fsHeaderSize:], true, nil
+}
+
+func (backend *FS) Set(key, value []byte, expiry time.Time) (int, bool, error) {
+	path := backend.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o770); err != nil {
+		return 0, false, err
+	}
+	// Write to a temporary file first, so that readers never see partial values.
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	var header [fsHeaderSize]byte
+	if !expiry.IsZero() {
+		binary.BigEndian.PutUint64(header[:], uint64(expiry.UnixNano()))
+	}
+	if _, err := f.Write(header[:]); err != nil {
+		_ = f.Close()
+		return 0, false, err
+	}
+	if _, err := f.Write(value); err != nil {
+		_ = f.Close()
+		return 0, false, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, false, err
+	}
+	replacedSize, replaced := 0, false
+	if info, err := os.Stat(path); err == nil {
+		replacedSize, replaced = int(info.Size())-fsHeaderSize, true
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return 0, false, err
+	}
+	backend.mu.Lock()
+	delete(backend.hits, string(key))
+	backend.mu.Unlock()
+	return replacedSize, replaced, nil
+}
+
+func (backend *FS) Touch(key []byte, expiry time.Time) (bool, error) {
+	f, err := os.OpenFile(backend.path(key), os.O_RDWR, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	var header [fsHeaderSize]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return false, fmt.Errorf("corrupted cache file %x", key)
+	}
+	if expired(header[:], time.Now()) {
+		return false, nil
+	}
+	binary.BigEndian.PutUint64(header[:], 0)
+	if !expiry.IsZero() {
+		binary.BigEndian.PutUint64(header[:], uint64(expiry.UnixNano()))
+	}
+	if _, err := f.WriteAt(header[:], 0); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (backend *FS) Delete(key []byte) error {
+	backend.mu.Lock()
+	delete(backend.hits, string(key))
+	backend.mu.Unlock()
+	err := os.Remove(backend.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (backend *FS) Entries(fn func(Entry) bool) error {
+	err := filepath.WalkDir(backend.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		key, err := backend.key(path)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() < fsHeaderSize {
+			return nil
+		}
+		backend.mu.Lock()
+		hits := backend.hits[string(key)]
+		backend.mu.Unlock()
+		e := Entry{Key: key, Size: int(info.Size() - fsHeaderSize), Accessed: info.ModTime(), Hits: hits}
+		if !fn(e) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return err
+}
+
+// Scan relies on files being walked in lexical order, which is the order of keys.
+func (backend *FS) Scan(after []byte, limit int) ([]Record, error) {
+	var records []Record
+	now := time.Now()
+	start := hex.EncodeToString(after)
+	err := filepath.WalkDir(backend.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Skip subdirectories preceding the one of the last scanned key.
+			if path != backend.dir && len(start) > 2 && d.Name() < start[:2] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		key, err := backend.key(path)
+		if err != nil || bytes.Compare(key, after) <= 0 {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || len(data) < fsHeaderSize || expired(data, now) {
+			return nil
+		}
+		r := Record{Key: key, Value: data[fsHeaderSize:]}
+		if expiry := binary.BigEndian.Uint64(data); expiry != 0 {
+			r.Expiry = time.Unix(0, int64(expiry))
+		}
+		records = append(records, r)
+		if len(records) == limit {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return records, err
+}
+
+// key returns the key of the file.
+func (backend *FS) key(path string) ([]byte, error) {
+	rel, err := filepath.Rel(backend.dir, path)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.ReplaceAll(rel, string(filepath.Separator), ""))
+}
+
+func (backend *FS) Cleanup(now time.Time) error {
+	return filepath.WalkDir(backend.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		var header [fsHeaderSize]byte
+		_, err = io.ReadFull(f, header[:])
+		_ = f.Close()
+		if err == nil && expired(header[:], now) {
+			if key, err := backend.key(path); err == nil {
+				_ = backend.Delete(key)
+			}
+		}
+		return nil
+	})
+}
+
+func (backend *FS) Close() error {
+	return nil
+}
+
+// expired reports whether the file with the header has expired by now.
+func expired(header []byte, now time.Time) bool {
+	expiry := binary.BigEndian.Uint64(header[:fsHeaderSize])
+	return expiry != 0 && expiry <= uint64(now.UnixNano())
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Memory is a backend storing values in memory, evicting least recently used ones
+// when the number or the total size of values exceeds the limits.
+type Memory struct {
+	maxEntries int
+	maxSize    int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // Most recently used entries first.
+	size    int64      // Total size of values.
+}
+
+type memoryEntry struct {
+	key      string
+	value    []byte
+	expiry   time.Time
+	accessed time.Time
+	hits     int
+}
+
+// NewMemory returns a memory backend holding up to maxEntries values of up to maxSize bytes
+// in total, each limit is unbounded if zero.
+func NewMemory(maxEntries int, maxSize int64) *Memory {
+	return &Memory{
+		maxEntries: maxEntries,
+		maxSize:    maxSize,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+func (backend *Memory) Get(key []byte) ([]byte, bool, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	el, ok := backend.entries[string(key)]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expiry.IsZero() && !e.expiry.After(time.Now()) {
+		return nil, false, nil
+	}
+	e.accessed = time.Now()
+	e.hits++
+	backend.lru.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (backend *Memory) Set(key, value []byte, expiry time.Time) (int, bool, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	replacedSize := 0
+	el, replaced := backend.entries[string(key)]
+	if replaced {
+		e := el.Value.(*memoryEntry)
+		replacedSize = len(e.value)
+		backend.size += int64(len(value) - len(e.value))
+		e.value, e.expiry, e.accessed, e.hits = value, expiry, time.Now(), 0
+		backend.lru.MoveToFront(el)
+	} else {
+		e := &memoryEntry{key: string(key), value: value, expiry: expiry, accessed: time.Now()}
+		backend.entries[e.key] = backend.lru.PushFront(e)
+		backend.size += int64(len(value))
+	}
+	// The value just set is kept, even if it exceeds the size limit alone.
+	for backend.lru.Len() > 1 && backend.exceeds() {
+		backend.remove(backend.lru.Back())
+	}
+	return replacedSize, replaced, nil
+}
+
+func (backend *Memory) exceeds() bool {
+	return (backend.maxEntries > 0 && backend.lru.Len() > backend.maxEntries) ||
+		(backend.maxSize > 0 && backend.size > backend.maxSize)
+}
+
+func (backend *Memory) Touch(key []byte, expiry time.Time) (bool, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	el, ok := backend.entries[string(key)]
+	if !ok {
+		return false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expiry.IsZero() && !e.expiry.After(time.Now()) {
+		return false, nil
+	}
+	e.expiry = expiry
+	return true, nil
+}
+
+func (backend *Memory) Delete(key []byte) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if el, ok := backend.entries[string(key)]; ok {
+		backend.remove(el)
+	}
+	return nil
+}
+
+func (backend *Memory) Entries(fn func(Entry) bool) error {
+	backend.mu.Lock()
+	entries := make([]Entry, 0, backend.lru.Len())
+	for el := backend.lru.Front(); el != nil; el = el.Next() {
+		e := el.Value.(*memoryEntry)
+		entries = append(entries, Entry{Key: []byte(e.key), Size: len(e.value), Accessed: e.accessed, Hits: e.hits})
+	}
+	backend.mu.Unlock()
+	for _, e := range entries {
+		if !fn(e) {
+			break
+		}
+	}
+	return nil
+}
+
+func (backend *Memory) Scan(after []byte, limit int) ([]Record, error) {
+	now := time.Now()
+	backend.mu.Lock()
+	var records []Record
+	for key, el := range backend.entries {
+		e := el.Value.(*memoryEntry)
+		if key <= string(after) || (!e.expiry.IsZero() && !e.expiry.After(now)) {
+			continue
+		}
+		records = append(records, Record{Key: []byte(key), Value: e.value, Expiry: e.expiry})
+	}
+	backend.mu.Unlock()
+	slices.SortFunc(records, func(a, b Record) int { return bytes.Compare(a.Key, b.Key) })
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func (backend *Memory) Cleanup(now time.Time) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	for el := backend.lru.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*memoryEntry); !e.expiry.IsZero() && e.expiry.Before(now) {
+			backend.remove(el)
+		}
+		el = next
+	}
+	return nil
+}
+
+func (backend *Memory) remove(el *list.Element) {
+	e := el.Value.(*memoryEntry)
+	backend.lru.Remove(el)
+	delete(backend.entries, e.key)
+	backend.size -= int64(len(e.value))
+}
+
+func (backend *Memory) Close() error {
+	return nil
+}
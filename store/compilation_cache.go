@@ -2,6 +2,7 @@ package store
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 
@@ -12,8 +13,11 @@ import (
 
 type CompilationCacheKey struct {
 	CompilerName    string
+	Toolchain       compilers.Toolchain
 	CompilerOptions compilers.CompilerOptions
-	Code            []byte
+	// Resolved versions of the modules required by the code.
+	Modules []string
+	Code    []byte
 }
 
 type CompilationCacheValue struct {
@@ -23,18 +27,38 @@ type CompilationCacheValue struct {
 
 type CompilationCache = cache.Cache[CompilationCacheKey, CompilationCacheValue]
 
-func NewCompilationCache(filename string) (*CompilationCache, error) {
-	return cache.New[CompilationCacheKey, CompilationCacheValue](filename)
+func NewCompilationCache(backend cache.Backend, opts ...cache.Option[CompilationCacheKey, CompilationCacheValue]) *CompilationCache {
+	return cache.NewWithBackend(backend, opts...)
 }
 
+// Hash is prefixed with the hash of the compiler name, see [CompilerKeyPrefix].
 func (k CompilationCacheKey) Hash() []byte {
-	var sum [32]byte
+	var sum [compilerPrefixSize + sha256.Size]byte
+	copy(sum[:], CompilerKeyPrefix(k.CompilerName))
 	h := sha256.New()
 	br := bufio.NewWriter(h)
 	_, _ = br.WriteString(k.CompilerName)
 	je := json.NewEncoder(br)
+	_ = je.Encode(k.Toolchain)
 	_ = je.Encode(k.CompilerOptions)
+	_ = je.Encode(k.Modules)
 	_, _ = br.Write(k.Code)
 	_ = br.Flush()
-	return h.Sum(sum[:0])
+	return h.Sum(sum[:compilerPrefixSize])
+}
+
+const compilerPrefixSize = 8
+
+// CompilerKeyPrefix returns the prefix of hashes of keys with the compiler name.
+func CompilerKeyPrefix(compilerName string) []byte {
+	sum := sha256.Sum256([]byte(compilerName))
+	return sum[:compilerPrefixSize]
+}
+
+// InvalidateCompiler removes cached results of the compiler, returning the number of removed entries.
+func InvalidateCompiler(c *CompilationCache, compilerName string) (int, error) {
+	prefix := CompilerKeyPrefix(compilerName)
+	return c.DeleteFunc(func(key []byte) bool {
+		return bytes.HasPrefix(key, prefix)
+	})
 }
@@ -1,6 +1,20 @@
 package store
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
 	"github.com/w1ck3dg0ph3r/goce/pkg/cache"
 	"github.com/w1ck3dg0ph3r/goce/pkg/shortid"
 )
@@ -11,18 +25,164 @@ type SharedCodeKey struct {
 
 type SharedCodeValue struct {
 	Code []byte
+	// ID of the shared code this one is forked from, empty if none.
+	Parent string
+	// SHA-256 of the token authorizing deletion by the creator, empty for code shared
+	// before delete tokens were introduced or after the creator has deleted it.
+	DeleteTokenHash []byte
+	// SHA-256 of the tokens of those who shared the same code after its creator.
+	SharerTokenHashes [][]byte
+	// Expiry of the shared code, zero if it never expires or was shared before
+	// expiry was recorded.
+	Expires time.Time
+}
+
+// NewDeleteToken returns a random token authorizing deletion of shared code and its hash.
+func NewDeleteToken() (token string, hash []byte) {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	token = hex.EncodeToString(b[:])
+	sum := sha256.Sum256([]byte(token))
+	return token, sum[:]
+}
+
+// CanDelete reports whether the token authorizes deletion of the shared code by any of
+// those who shared it.
+func (v SharedCodeValue) CanDelete(token string) bool {
+	return v.holder(token) != -1
+}
+
+// holder returns the index of the holder of the token among those who shared the code,
+// where the creator is -2, or -1 if there is none.
+func (v SharedCodeValue) holder(token string) int {
+	sum := sha256.Sum256([]byte(token))
+	if len(v.DeleteTokenHash) != 0 && subtle.ConstantTimeCompare(sum[:], v.DeleteTokenHash) == 1 {
+		return -2
+	}
+	for i, hash := range v.SharerTokenHashes {
+		if subtle.ConstantTimeCompare(sum[:], hash) == 1 {
+			return i
+		}
+	}
+	return -1
 }
 
 type SharedCode = cache.Cache[SharedCodeKey, SharedCodeValue]
 
-func NewSharedCode(filename string) (*SharedCode, error) {
-	return cache.New[SharedCodeKey, SharedCodeValue](filename)
+// NewSharedCode returns the shared code store. Shared code is kept when SharedCodeValue
+// gains new fields, so they must have meaningful zero values, and code shared before
+// values were versioned remains readable.
+func NewSharedCode(backend cache.Backend, opts ...cache.Option[SharedCodeKey, SharedCodeValue]) *SharedCode {
+	opts = append([]cache.Option[SharedCodeKey, SharedCodeValue]{
+		cache.WithTypeVersioning[SharedCodeKey, SharedCodeValue](false),
+		cache.WithLegacyValues[SharedCodeKey, SharedCodeValue](true),
+	}, opts...)
+	return cache.NewWithBackend(backend, opts...)
 }
 
 func NewSharedCodeKey() SharedCodeKey {
 	return SharedCodeKey{id: shortid.New()}
 }
 
+// ContentSharedCodeKey returns the key derived from the content of shared code and its
+// TTL, which is the same for the same code forked from the same parent with the same TTL.
+func ContentSharedCodeKey(val SharedCodeValue, ttl time.Duration) SharedCodeKey {
+	h := sha256.New()
+	_, _ = h.Write([]byte(val.Parent))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(binary.BigEndian.AppendUint64(nil, uint64(ttl)))
+	_, _ = h.Write(val.Code)
+	var k SharedCodeKey
+	copy(k.id[:], h.Sum(nil))
+	return k
+}
+
+// Maximum number of those who share the same code by the same key, further shares
+// are stored by random keys.
+const maxSharers = 100
+
+// Sharing and unsharing are serialized so that concurrent shares of the same code do not
+// overwrite each other's delete tokens. Deduplication is best-effort across processes that
+// use the same backend: the code shared last by one of them keeps only its delete tokens.
+var shareMu sync.Mutex
+
+// Share stores shared code by its content key, see [ContentSharedCodeKey], reporting
+// whether it is stored anew. Sharing the same code with the same TTL again returns the same
+// key, extends its expiry to the later of the two and adds the delete token of val to those
+// of the stored one, see [Unshare]. The value is updated to the stored one.
+//
+// A random key is used if the content key is taken by different code or too many sharers.
+func Share(c *SharedCode, val *SharedCodeValue, ttl time.Duration) (SharedCodeKey, bool, error) {
+	shareMu.Lock()
+	defer shareMu.Unlock()
+
+	if ttl > 0 {
+		val.Expires = time.Now().Add(ttl)
+	}
+	k := ContentSharedCodeKey(*val, ttl)
+	var existing SharedCodeValue
+	found, err := c.Get(k, &existing)
+	if err != nil {
+		return k, false, err
+	}
+	if found && (existing.Parent != val.Parent || !bytes.Equal(existing.Code, val.Code) ||
+		len(existing.SharerTokenHashes) >= maxSharers) {
+		k, found = NewSharedCodeKey(), false
+	}
+	if found {
+		if existing.Expires.IsZero() || val.Expires.IsZero() {
+			existing.Expires = time.Time{}
+		} else if val.Expires.After(existing.Expires) {
+			existing.Expires = val.Expires
+		}
+		existing.SharerTokenHashes = append(existing.SharerTokenHashes, val.DeleteTokenHash)
+		*val = existing
+	}
+
+	ttl = 0
+	if !val.Expires.IsZero() {
+		ttl = time.Until(val.Expires)
+	}
+	if err := c.Set(k, *val, ttl); err != nil {
+		return k, false, err
+	}
+	return k, !found, nil
+}
+
+// ErrInvalidDeleteToken is returned when the token does not authorize deletion of shared code.
+var ErrInvalidDeleteToken = errors.New("invalid delete token")
+
+// Unshare releases the shared code from the holder of the delete token, deleting it when
+// no one else who shared it remains. Reports whether the shared code is found.
+func Unshare(c *SharedCode, k SharedCodeKey, token string) (bool, error) {
+	shareMu.Lock()
+	defer shareMu.Unlock()
+
+	var val SharedCodeValue
+	if found, err := c.Get(k, &val); !found || err != nil {
+		return found, err
+	}
+	switch i := val.holder(token); i {
+	case -1:
+		return true, ErrInvalidDeleteToken
+	case -2:
+		val.DeleteTokenHash = nil
+	default:
+		val.SharerTokenHashes = slices.Delete(val.SharerTokenHashes, i, i+1)
+	}
+	if len(val.DeleteTokenHash) == 0 && len(val.SharerTokenHashes) == 0 {
+		return true, c.Delete(k)
+	}
+
+	var ttl time.Duration
+	if !val.Expires.IsZero() {
+		if ttl = time.Until(val.Expires); ttl <= 0 {
+			return true, c.Delete(k)
+		}
+	}
+	return true, c.Set(k, val, ttl)
+}
+
 func ParseSharedCodeKey(s string) (SharedCodeKey, error) {
 	id, err := shortid.Parse(s)
 	if err != nil {
@@ -38,3 +198,102 @@ func (k SharedCodeKey) String() string {
 func (k SharedCodeKey) Hash() []byte {
 	return k.id[:]
 }
+
+func sharedCodeKeyFromHash(hash []byte) (SharedCodeKey, error) {
+	var k SharedCodeKey
+	if len(hash) != len(k.id) {
+		return k, fmt.Errorf("invalid shared code key %x", hash)
+	}
+	copy(k.id[:], hash)
+	return k, nil
+}
+
+// SharedCodeRecord is a shared code snippet in an export.
+type SharedCodeRecord struct {
+	ID              string `json:"id"`
+	Code            string `json:"code"`
+	Parent          string `json:"parent,omitempty"`
+	DeleteTokenHash []byte `json:"deleteTokenHash,omitempty"`
+	// Hashes of delete tokens of those who shared the same code after its creator.
+	SharerTokenHashes [][]byte `json:"sharerTokenHashes,omitempty"`
+	// Nil if the snippet does not expire.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// ExportSharedCode writes shared code snippets as JSON lines of [SharedCodeRecord],
+// returning the number of written snippets.
+func ExportSharedCode(c *SharedCode, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+	var err error
+	scanErr := c.Scan(func(item cache.Item[SharedCodeValue]) bool {
+		var k SharedCodeKey
+		if k, err = sharedCodeKeyFromHash(item.Key); err != nil {
+			return false
+		}
+		rec := SharedCodeRecord{
+			ID:                k.String(),
+			Code:              string(item.Value.Code),
+			Parent:            item.Value.Parent,
+			DeleteTokenHash:   item.Value.DeleteTokenHash,
+			SharerTokenHashes: item.Value.SharerTokenHashes,
+		}
+		if !item.Expiry.IsZero() {
+			rec.Expires = &item.Expiry
+		}
+		if err = enc.Encode(rec); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	return n, errors.Join(scanErr, err)
+}
+
+// ImportSharedCode stores shared code snippets written by [ExportSharedCode], replacing
+// existing ones with the same IDs. Code of each snippet is read with [ParseSharedSession]
+// and checked with validate. Expired snippets are skipped. Returns the number of stored snippets.
+func ImportSharedCode(c *SharedCode, r io.Reader, validate func(s *SharedSession) error) (int, error) {
+	dec := json.NewDecoder(r)
+	n := 0
+	for i := 1; ; i++ {
+		var rec SharedCodeRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", i, err)
+		}
+		k, err := ParseSharedCodeKey(rec.ID)
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", i, err)
+		}
+		session, err := ParseSharedSession([]byte(rec.Code))
+		if err == nil {
+			err = validate(&session)
+		}
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", i, err)
+		}
+		var ttl time.Duration
+		if rec.Expires != nil {
+			if ttl = time.Until(*rec.Expires); ttl <= 0 {
+				continue
+			}
+		}
+		val := SharedCodeValue{
+			Code:              []byte(rec.Code),
+			Parent:            rec.Parent,
+			DeleteTokenHash:   rec.DeleteTokenHash,
+			SharerTokenHashes: rec.SharerTokenHashes,
+		}
+		if rec.Expires != nil {
+			val.Expires = *rec.Expires
+		}
+		if err := c.Set(k, val, ttl); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,111 @@
+package store_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w1ck3dg0ph3r/goce/pkg/cache"
+	"github.com/w1ck3dg0ph3r/goce/store"
+)
+
+func TestSharedCodeLegacy(t *testing.T) {
+	backend := cache.NewMemory(0, 0)
+	c := store.NewSharedCode(backend)
+	t.Cleanup(c.Close)
+
+	// Code shared before values had an envelope and a parent, delete token and expiry.
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(struct{ Code []byte }{[]byte("package main")}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	key := store.NewSharedCodeKey()
+	if _, _, err := backend.Set(key.Hash(), legacy.Bytes(), time.Time{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var val store.SharedCodeValue
+	if ok, err := c.Get(key, &val); !ok || err != nil || string(val.Code) != "package main" {
+		t.Fatalf("expected to find legacy shared code, got %q, %v, %v", val.Code, ok, err)
+	}
+	if _, err := store.DecodeSharedSession(val.Code); !errors.Is(err, store.ErrInvalidSession) {
+		t.Errorf("expected legacy shared code not to decode as a session, got %v", err)
+	}
+	if s, err := store.ParseSharedSession(val.Code); err != nil || len(s.Tabs) != 1 || s.Tabs[0].Code != "package main" {
+		t.Errorf("expected legacy shared code to be read as a session, got %+v, %v", s, err)
+	}
+
+	var export bytes.Buffer
+	if n, err := store.ExportSharedCode(c, &export); n != 1 || err != nil {
+		t.Errorf("expected to export legacy shared code, got %d, %v", n, err)
+	}
+}
+
+func TestShareUnshare(t *testing.T) {
+	c := store.NewSharedCode(cache.NewMemory(0, 0))
+	t.Cleanup(c.Close)
+
+	share := func(ttl time.Duration) (store.SharedCodeKey, string) {
+		token, hash := store.NewDeleteToken()
+		k, _, err := store.Share(c, &store.SharedCodeValue{Code: []byte("package main"), DeleteTokenHash: hash}, ttl)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return k, token
+	}
+	k1, token1 := share(time.Hour)
+	k2, token2 := share(time.Hour)
+	if k1 != k2 {
+		t.Fatalf("expected the same key for the same code, got %s and %s", k1, k2)
+	}
+	if k3, _ := share(2 * time.Hour); k3 == k1 {
+		t.Errorf("expected a different key for a different ttl")
+	}
+
+	if _, err := store.Unshare(c, k1, "invalid"); !errors.Is(err, store.ErrInvalidDeleteToken) {
+		t.Errorf("expected invalid delete token, got %v", err)
+	}
+	for i, token := range []string{token1, token2} {
+		if found, err := store.Unshare(c, k1, token); !found || err != nil {
+			t.Fatalf("expected to unshare, got %v, %v", found, err)
+		}
+		var val store.SharedCodeValue
+		if found, _ := c.Get(k1, &val); found != (i == 0) {
+			t.Errorf("expected shared code to be kept only while it has holders, got %v after %d", found, i+1)
+		}
+	}
+}
+
+func TestImportSharedCode(t *testing.T) {
+	c := store.NewSharedCode(cache.NewMemory(0, 0))
+	t.Cleanup(c.Close)
+
+	valid := `{"version":1,"tabs":[{"name":"main.go","type":"code","code":"package main"}]}`
+	records := func(codes ...string) *bytes.Buffer {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, code := range codes {
+			if err := enc.Encode(store.SharedCodeRecord{ID: store.NewSharedCodeKey().String(), Code: code}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		return &buf
+	}
+	validate := func(s *store.SharedSession) error {
+		return s.Validate(1, func(string) bool { return false }, "")
+	}
+
+	if n, err := store.ImportSharedCode(c, records(valid, "package main"), validate); n != 2 || err != nil {
+		t.Errorf("expected sessions and legacy code to be imported, got %d, %v", n, err)
+	}
+	for _, code := range []string{"{", `{"tabs":[]}`, `{"tabs":[{"name":"a.go","type":"code"},{"name":"b.go","type":"code"}]}`} {
+		n, err := store.ImportSharedCode(c, records(valid, code), validate)
+		if n != 1 || err == nil || !strings.HasPrefix(err.Error(), "record 2: ") {
+			t.Errorf("expected record 2 to be rejected for %q, got %d, %v", code, n, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/w1ck3dg0ph3r/goce/compilers"
+)
+
+// SharedSessionVersion is the current version of the [SharedSession] schema.
+//
+// Versions:
+//   - 0: session without a version, or plain source code shared before sessions;
+//   - 1: session with a version.
+const SharedSessionVersion = 1
+
+// SharedSession is the state of the UI shared with a link.
+type SharedSession struct {
+	Version int         `json:"version"`
+	Tabs    []SharedTab `json:"tabs"`
+	// Index of the selected tab, -1 if none.
+	ActiveTab int `json:"activeTab"`
+}
+
+// SharedTab is a tab of a shared session, either a code or a diff tab depending on its type.
+type SharedTab struct {
+	Name string        `json:"name"`
+	Type SharedTabType `json:"type"`
+	*SharedCodeTab
+	*SharedDiffTab
+}
+
+type SharedTabType string
+
+const (
+	SharedTabCode SharedTabType = "code"
+	SharedTabDiff SharedTabType = "diff"
+)
+
+// SharedCodeTab is a tab with source code.
+type SharedCodeTab struct {
+	Code     string               `json:"code"`
+	Settings SharedSourceSettings `json:"settings"`
+}
+
+// SharedSourceSettings are settings of the compilation of a code tab.
+type SharedSourceSettings struct {
+	// Compiler selected in the tab, the default one if nil.
+	Compiler        *SharedCompiler           `json:"compiler,omitempty"`
+	CompilerOptions compilers.CompilerOptions `json:"compilerOptions"`
+}
+
+type SharedCompiler struct {
+	Name string `json:"name"`
+	compilers.CompilerInfo
+}
+
+// SharedDiffTab is a tab comparing compilation results of two code tabs.
+type SharedDiffTab struct {
+	// Indices of the compared code tabs, -1 if none.
+	OriginalSource int  `json:"originalSource"`
+	ModifiedSource int  `json:"modifiedSource"`
+	Inline         bool `json:"inline"`
+}
+
+// Maximum length of tab names in characters.
+const maxTabNameLength = 100
+
+var ErrInvalidSession = errors.New("invalid shared session")
+
+// DecodeSharedSession decodes the shared session from JSON, migrating it to the current version.
+func DecodeSharedSession(data []byte) (SharedSession, error) {
+	var s SharedSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%w: %w", ErrInvalidSession, err)
+	}
+	if s.Tabs == nil {
+		return s, fmt.Errorf("%w: not a session", ErrInvalidSession)
+	}
+	return s, s.migrate()
+}
+
+// ParseSharedSession decodes the stored shared session like [DecodeSharedSession]. Data that
+// is not a session is taken as source code shared before sessions were introduced.
+func ParseSharedSession(data []byte) (SharedSession, error) {
+	s, err := DecodeSharedSession(data)
+	if err == nil || !utf8.Valid(data) || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return s, err
+	}
+	s = SharedSession{Tabs: []SharedTab{{
+		Name:          "main.go",
+		Type:          SharedTabCode,
+		SharedCodeTab: &SharedCodeTab{Code: string(data)},
+	}}}
+	return s, s.migrate()
+}
+
+// migrate migrates the session to the current version.
+func (s *SharedSession) migrate() error {
+	if s.Version > SharedSessionVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSession, s.Version)
+	}
+	for s.Version < SharedSessionVersion {
+		sharedSessionMigrations[s.Version](s)
+		s.Version++
+	}
+	return nil
+}
+
+// sharedSessionMigrations migrate sessions from the version of the index to the next one.
+var sharedSessionMigrations = [SharedSessionVersion]func(*SharedSession){
+	0: func(s *SharedSession) {
+		// Unversioned sessions do not have the fields of the tabs of other types removed.
+		for i := range s.Tabs {
+			s.Tabs[i].normalize()
+		}
+		if s.ActiveTab < -1 || s.ActiveTab >= len(s.Tabs) {
+			s.ActiveTab = -1
+		}
+	},
+}
+
+// DefaultCodeTab returns the index of the code tab to show when none is chosen: the active
+// tab if it is a code tab, the first code tab otherwise, or -1 if there are none.
+func (s *SharedSession) DefaultCodeTab() int {
+	if s.ActiveTab >= 0 && s.ActiveTab < len(s.Tabs) && s.Tabs[s.ActiveTab].Type == SharedTabCode {
+		return s.ActiveTab
+	}
+	for i, t := range s.Tabs {
+		if t.Type == SharedTabCode {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalize removes fields of other tab types and adds missing ones of the tab type.
+func (t *SharedTab) normalize() {
+	switch t.Type {
+	case SharedTabCode:
+		t.SharedDiffTab = nil
+		if t.SharedCodeTab == nil {
+			t.SharedCodeTab = &SharedCodeTab{}
+		}
+	case SharedTabDiff:
+		t.SharedCodeTab = nil
+		if t.SharedDiffTab == nil {
+			t.SharedDiffTab = &SharedDiffTab{OriginalSource: -1, ModifiedSource: -1}
+		}
+	}
+}
+
+// Validate checks the session has at most maxTabs tabs, unbounded if zero, and uses compilers
+// for which isKnownCompiler returns true. Options of tabs without a compiler are checked for
+// defaultArchitecture, the one of the default compiler. Tabs are normalized to have only
+// the fields of their type, and compiler information is filled from compiler names.
+func (s *SharedSession) Validate(maxTabs int, isKnownCompiler func(name string) bool, defaultArchitecture string) error {
+	if len(s.Tabs) == 0 {
+		return fmt.Errorf("%w: no tabs", ErrInvalidSession)
+	}
+	if maxTabs > 0 && len(s.Tabs) > maxTabs {
+		return fmt.Errorf("%w: %d tabs, at most %d are allowed", ErrInvalidSession, len(s.Tabs), maxTabs)
+	}
+	if s.ActiveTab < -1 || s.ActiveTab >= len(s.Tabs) {
+		return fmt.Errorf("%w: active tab %d is out of range", ErrInvalidSession, s.ActiveTab)
+	}
+	for i := range s.Tabs {
+		if err := s.validateTab(i, isKnownCompiler, defaultArchitecture); err != nil {
+			return fmt.Errorf("%w: tab %d: %w", ErrInvalidSession, i, err)
+		}
+	}
+	return nil
+}
+
+func (s *SharedSession) validateTab(i int, isKnownCompiler func(name string) bool, defaultArchitecture string) error {
+	t := &s.Tabs[i]
+	if t.Name == "" || utf8.RuneCountInString(t.Name) > maxTabNameLength {
+		return fmt.Errorf("name must be 1 to %d characters long", maxTabNameLength)
+	}
+	switch t.Type {
+	case SharedTabCode, SharedTabDiff:
+		t.normalize()
+	default:
+		return fmt.Errorf("unknown type %q", t.Type)
+	}
+
+	if t.Type == SharedTabDiff {
+		for _, src := range []int{t.OriginalSource, t.ModifiedSource} {
+			if src == -1 {
+				continue
+			}
+			if src < 0 || src >= len(s.Tabs) || s.Tabs[src].Type != SharedTabCode {
+				return fmt.Errorf("source %d is not a code tab", src)
+			}
+		}
+		return nil
+	}
+
+	settings := &t.Settings
+	architecture := defaultArchitecture
+	if c := settings.Compiler; c != nil {
+		info, err := compilers.ParseInfo(c.Name)
+		if err != nil {
+			return err
+		}
+		if !isKnownCompiler(c.Name) {
+			return fmt.Errorf("unknown compiler %q", c.Name)
+		}
+		c.CompilerInfo = info
+		architecture = info.Architecture
+	}
+	return settings.CompilerOptions.Validate(architecture)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/w1ck3dg0ph3r/goce/compilers"
 	"github.com/w1ck3dg0ph3r/goce/parsers"
+	"github.com/w1ck3dg0ph3r/goce/store"
 )
 
 func TestGoce(t *testing.T) {
@@ -139,6 +141,130 @@ func TestGoce(t *testing.T) {
 		})
 	})
 
+	t.Run("Diff", func(t *testing.T) {
+		type compileRequest struct {
+			Name    string                    `json:"name"`
+			Options compilers.CompilerOptions `json:"options"`
+			Code    string                    `json:"code"`
+		}
+		req := struct {
+			Before compileRequest `json:"before"`
+			After  compileRequest `json:"after"`
+		}{
+			Before: compileRequest{Name: availableCompilers[0].Name, Code: readTestFile("example.go")},
+			After: compileRequest{
+				Name: availableCompilers[0].Name,
+				Code: strings.Replace(readTestFile("example.go"), "func square", "//go:noinline\nfunc square", 1),
+			},
+		}
+		var res struct {
+			Diff parsers.ResultDiff `json:"diff"`
+		}
+		status, err := request("POST", "/api/diff", req, &res)
+		if err != nil {
+			t.Error(err)
+		}
+		if status != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, status)
+		}
+		if len(res.Diff.Functions) == 0 {
+			t.Errorf("expected function diffs")
+		}
+		if len(res.Diff.Diagnostics.LostInlining) != 1 || res.Diff.Diagnostics.LostInlining[0] != "square" {
+			t.Errorf("expected square to lose inlining, got %q", res.Diff.Diagnostics.LostInlining)
+		}
+	})
+
+	t.Run("Bisect", func(t *testing.T) {
+		req := struct {
+			Platform     string `json:"platform"`
+			Architecture string `json:"architecture"`
+			Code         string `json:"code"`
+		}{
+			Platform:     availableCompilers[0].Platform,
+			Architecture: availableCompilers[0].Architecture,
+			Code:         readTestFile("example.go"),
+		}
+		var res struct {
+			Groups []struct {
+				Versions []string `json:"versions"`
+				Error    string   `json:"error"`
+			} `json:"groups"`
+			Boundaries []struct {
+				Before string `json:"before"`
+				After  string `json:"after"`
+			} `json:"boundaries"`
+		}
+		status, err := request("POST", "/api/bisect", req, &res)
+		if err != nil {
+			t.Error(err)
+		}
+		if status != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, status)
+		}
+		versions := 0
+		for _, g := range res.Groups {
+			versions += len(g.Versions)
+			if g.Error != "" {
+				t.Errorf("unexpected error for %v: %s", g.Versions, g.Error)
+			}
+		}
+		if len(res.Groups) == 0 || len(res.Boundaries) != len(res.Groups)-1 {
+			t.Errorf("expected boundaries between groups, got %d groups and %d boundaries", len(res.Groups), len(res.Boundaries))
+		}
+		if versions == 0 {
+			t.Errorf("expected compiled versions")
+		}
+
+		// Platform defaults to the one of the default compiler.
+		req.Platform = ""
+		status, err = request("POST", "/api/bisect", req, &res)
+		if err != nil {
+			t.Error(err)
+		}
+		if status != http.StatusOK || len(res.Groups) == 0 {
+			t.Errorf("expected versions for the default platform, got status %d and %d groups", status, len(res.Groups))
+		}
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		req := struct {
+			Names   []string                    `json:"names"`
+			Options []compilers.CompilerOptions `json:"options"`
+			Code    string                      `json:"code"`
+		}{
+			Names:   []string{availableCompilers[0].Name},
+			Options: []compilers.CompilerOptions{{}, {DisableOptimizations: true}},
+			Code:    readTestFile("example.go"),
+		}
+		var res struct {
+			Cells []struct {
+				Name        string `json:"name"`
+				Error       string `json:"error"`
+				BuildFailed bool   `json:"buildFailed"`
+				Assembly    string `json:"assembly"`
+			} `json:"cells"`
+		}
+		status, err := request("POST", "/api/batch", req, &res)
+		if err != nil {
+			t.Error(err)
+		}
+		if status != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, status)
+		}
+		if len(res.Cells) != 2 {
+			t.Fatalf("expected 2 cells, got %d", len(res.Cells))
+		}
+		for _, c := range res.Cells {
+			if c.Error != "" || c.BuildFailed || c.Assembly == "" {
+				t.Errorf("expected successful compilation, got %+v", c)
+			}
+		}
+		if res.Cells[0].Assembly == res.Cells[1].Assembly {
+			t.Errorf("expected different assembly for different options")
+		}
+	})
+
 	t.Run("Share", func(t *testing.T) {
 		t.Run("NotFound", func(t *testing.T) {
 			status, _ := request("GET", "/api/shared/3fH9yF8z", nil, nil)
@@ -149,12 +275,27 @@ func TestGoce(t *testing.T) {
 
 		var sharedID string
 		sharedCode := readTestFile("example.go")
+		sharedSession := map[string]any{
+			"tabs": []map[string]any{
+				{
+					"name": "main.go",
+					"type": "code",
+					"code": sharedCode,
+					"settings": map[string]any{
+						"compiler":        map[string]any{"name": availableCompilers[0].Name},
+						"compilerOptions": map[string]any{"disableInlining": true},
+					},
+				},
+				{"name": "diff", "type": "diff", "originalSource": 0, "modifiedSource": -1},
+			},
+			"activeTab": 0,
+		}
 
 		t.Run("ShareCode", func(t *testing.T) {
 			var res struct {
 				ID string `json:"id"`
 			}
-			status, err := request("POST", "/api/shared", sharedCode, &res)
+			status, err := request("POST", "/api/shared", sharedSession, &res)
 			if status != http.StatusOK {
 				t.Errorf("expected status %d, got %d", http.StatusNotFound, status)
 			}
@@ -168,7 +309,7 @@ func TestGoce(t *testing.T) {
 		})
 
 		t.Run("GetShared", func(t *testing.T) {
-			var res string
+			var res sharedSessionResponse
 			status, err := request("GET", "/api/shared/"+sharedID, nil, &res)
 			if status != http.StatusOK {
 				t.Errorf("expected status %d, got %d", http.StatusNotFound, status)
@@ -176,20 +317,430 @@ func TestGoce(t *testing.T) {
 			if err != nil {
 				t.Error(err)
 			}
-			if res != sharedCode {
-				t.Errorf("expected to get shared code, got %q", res)
+			if res.Version != 1 || len(res.Tabs) != 2 || res.Tabs[0].Code != sharedCode {
+				t.Fatalf("expected to get shared session, got %+v", res)
+			}
+			if res.Tabs[0].Settings.Compiler.Architecture != availableCompilers[0].Architecture {
+				t.Errorf("expected compiler info to be filled, got %+v", res.Tabs[0].Settings.Compiler)
+			}
+			if res.Tabs[1].Code != "" || res.Tabs[1].OriginalSource == nil || *res.Tabs[1].OriginalSource != 0 {
+				t.Errorf("expected diff tab, got %+v", res.Tabs[1])
+			}
+		})
+
+		t.Run("Embed", func(t *testing.T) {
+			var page string
+			status, err := request("GET", "/embed/"+sharedID, nil, &page)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+			}
+			for _, want := range []string{"<title>main.go - goce</title>", "TEXT", "application/json+oembed"} {
+				if !strings.Contains(page, want) {
+					t.Errorf("expected embedded page to contain %q", want)
+				}
+			}
+
+			status, _ = request("GET", "/embed/"+sharedID+"?tab=1", nil, nil)
+			if status != http.StatusNotFound {
+				t.Errorf("expected status %d for diff tab, got %d", http.StatusNotFound, status)
+			}
+		})
+
+		t.Run("OEmbed", func(t *testing.T) {
+			var res struct {
+				Type  string `json:"type"`
+				Title string `json:"title"`
+				HTML  string `json:"html"`
+				Width int    `json:"width"`
+			}
+			path := "/api/oembed?maxwidth=600&url=" + url.QueryEscape("http://localhost:9000/"+sharedID)
+			status, err := request("GET", path, nil, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+			}
+			if res.Type != "rich" || res.Title != "main.go" || res.Width != 600 {
+				t.Errorf("unexpected oembed response %+v", res)
+			}
+			if !strings.Contains(res.HTML, "/embed/"+sharedID) {
+				t.Errorf("expected embedded page in html, got %q", res.HTML)
+			}
+
+			for path, want := range map[string]int{
+				"/api/oembed?url=" + url.QueryEscape("http://example.com/"+sharedID):               http.StatusNotFound,
+				"/api/oembed?url=" + url.QueryEscape("http://localhost:9000/3fH9yF8z"):             http.StatusNotFound,
+				"/api/oembed?format=xml&url=" + url.QueryEscape("http://localhost:9000/"+sharedID): http.StatusNotImplemented,
+			} {
+				if status, _ := request("GET", path, nil, nil); status != want {
+					t.Errorf("expected status %d for %s, got %d", want, path, status)
+				}
+			}
+		})
+
+		t.Run("LegacyPlainCode", func(t *testing.T) {
+			// Code shared before sessions were introduced is stored as is.
+			id := store.NewSharedCodeKey().String()
+			record, err := json.Marshal(store.SharedCodeRecord{ID: id, Code: sharedCode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status, err := adminRequest("POST", "/api/admin/shared/import", string(record), nil); err != nil || status != http.StatusOK {
+				t.Fatalf("expected legacy code to be imported, got status %d and %v", status, err)
+			}
+			var res sharedSessionResponse
+			if _, err := request("GET", "/api/shared/"+id, nil, &res); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Tabs) != 1 || res.Tabs[0].Type != "code" || res.Tabs[0].Code != sharedCode {
+				t.Errorf("expected session with the shared code, got %+v", res)
+			}
+		})
+
+		t.Run("DefaultCompilerArchitectureLevel", func(t *testing.T) {
+			levels := compilers.ArchitectureLevels(availableCompilers[0].Architecture)
+			if len(levels) == 0 {
+				t.Skipf("no architecture levels for %s", availableCompilers[0].Architecture)
+			}
+			session := map[string]any{"tabs": []map[string]any{{"name": "main.go", "type": "code", "code": sharedCode, "settings": map[string]any{
+				"compilerOptions": map[string]any{"architectureLevel": levels[0]},
+			}}}}
+			if status, _ := request("POST", "/api/shared", session, nil); status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+		})
+
+		t.Run("InvalidSession", func(t *testing.T) {
+			invalid := []any{
+				"{",
+				"package main",
+				map[string]any{"tabs": []any{}},
+				map[string]any{"tabs": []map[string]any{{"name": "main.go", "type": "unknown"}}},
+				map[string]any{"tabs": []map[string]any{{"name": "main.go", "type": "code", "settings": map[string]any{
+					"compiler": map[string]any{"name": "go1.0 plan9/amd64"},
+				}}}},
+				map[string]any{"tabs": []map[string]any{{"name": "main.go", "type": "code", "settings": map[string]any{
+					"compiler":        map[string]any{"name": availableCompilers[0].Name},
+					"compilerOptions": map[string]any{"architectureLevel": "v9"},
+				}}}},
+				map[string]any{"version": 1, "tabs": []map[string]any{{"name": "main.go", "type": "code"}}, "activeTab": 3},
+				map[string]any{"version": 2, "tabs": []map[string]any{{"name": "main.go", "type": "code"}}},
+			}
+			for _, req := range invalid {
+				status, _ := request("POST", "/api/shared", req, nil)
+				if status != http.StatusBadRequest {
+					t.Errorf("expected status %d for %v, got %d", http.StatusBadRequest, req, status)
+				}
+			}
+		})
+
+		t.Run("Deduplicate", func(t *testing.T) {
+			var res struct {
+				ID          string `json:"id"`
+				DeleteToken string `json:"deleteToken"`
+			}
+			status, err := request("POST", "/api/shared", sharedSession, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+			if res.ID != sharedID {
+				t.Errorf("expected shared id %s, got %s", sharedID, res.ID)
+			}
+			if res.DeleteToken == "" {
+				t.Errorf("expected delete token for shared code shared again")
+			}
+
+			// Shared code is kept for the one who shared it first.
+			auth := map[string]string{"Authorization": "Bearer " + res.DeleteToken}
+			status, _ = requestWithHeaders("DELETE", "/api/shared/"+res.ID, auth, nil, nil)
+			if status != http.StatusNoContent {
+				t.Errorf("expected status %d, got %d", http.StatusNoContent, status)
+			}
+			status, _ = request("GET", "/api/shared/"+sharedID, nil, nil)
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+			status, _ = requestWithHeaders("DELETE", "/api/shared/"+res.ID, auth, nil, nil)
+			if status != http.StatusForbidden {
+				t.Errorf("expected status %d for used delete token, got %d", http.StatusForbidden, status)
+			}
+
+			if _, err := request("POST", "/api/shared?ttl=2h", sharedSession, &res); err != nil {
+				t.Error(err)
+			}
+			if res.ID == sharedID {
+				t.Errorf("expected shared code with a different ttl to have a different id")
+			}
+		})
+
+		t.Run("InvalidTTL", func(t *testing.T) {
+			for _, ttl := range []string{"abc", "0s", "1s", "100000h"} {
+				status, _ := request("POST", "/api/shared?ttl="+ttl, sharedSession, nil)
+				if status != http.StatusBadRequest {
+					t.Errorf("expected status %d for ttl %s, got %d", http.StatusBadRequest, ttl, status)
+				}
+			}
+		})
+
+		t.Run("Fork", func(t *testing.T) {
+			var res struct {
+				ID          string     `json:"id"`
+				DeleteToken string     `json:"deleteToken"`
+				Expires     *time.Time `json:"expires"`
+			}
+			status, err := request("POST", "/api/shared?permanent=true&parent="+sharedID, sharedSession, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+			}
+			if res.Expires != nil {
+				t.Errorf("expected permanent shared code, got expiry %v", res.Expires)
+			}
+
+			var info struct {
+				Parent string `json:"parent"`
+			}
+			if _, err := request("GET", "/api/shared/"+res.ID+"/info", nil, &info); err != nil {
+				t.Error(err)
+			}
+			if info.Parent != sharedID {
+				t.Errorf("expected parent %s, got %q", sharedID, info.Parent)
+			}
+
+			status, _ = requestWithHeaders("DELETE", "/api/shared/"+res.ID, map[string]string{"Authorization": "Bearer invalid"}, nil, nil)
+			if status != http.StatusForbidden {
+				t.Errorf("expected status %d, got %d", http.StatusForbidden, status)
+			}
+			status, _ = requestWithHeaders("DELETE", "/api/shared/"+res.ID, map[string]string{"Authorization": "Bearer " + res.DeleteToken}, nil, nil)
+			if status != http.StatusNoContent {
+				t.Errorf("expected status %d, got %d", http.StatusNoContent, status)
+			}
+			status, _ = request("GET", "/api/shared/"+res.ID, nil, nil)
+			if status != http.StatusNotFound {
+				t.Errorf("expected status %d, got %d", http.StatusNotFound, status)
+			}
+		})
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		req := map[string]string{"compiler": availableCompilers[0].Name}
+
+		t.Run("Unauthorized", func(t *testing.T) {
+			status, _ := request("POST", "/api/admin/cache/invalidate", req, nil)
+			if status != http.StatusUnauthorized {
+				t.Errorf("expected status %d, got %d", http.StatusUnauthorized, status)
+			}
+		})
+
+		t.Run("InvalidateCompiler", func(t *testing.T) {
+			var res struct {
+				Deleted int `json:"deleted"`
+			}
+			status, err := adminRequest("POST", "/api/admin/cache/invalidate", req, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+			// Earlier tests compiled with the compiler.
+			if res.Deleted == 0 {
+				t.Errorf("expected cached results to be deleted")
+			}
+		})
+
+		t.Run("Stats", func(t *testing.T) {
+			var res struct {
+				CompilationCache *struct {
+					Entries int `json:"entries"`
+				} `json:"compilationCache"`
+				SharedCode struct {
+					Entries int `json:"entries"`
+				} `json:"sharedCode"`
+			}
+			status, err := adminRequest("GET", "/api/admin/stats", nil, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+			if res.CompilationCache == nil {
+				t.Errorf("expected compilation cache stats")
+			}
+			if res.SharedCode.Entries == 0 {
+				t.Errorf("expected shared code entries")
+			}
+		})
+
+		t.Run("PurgeCache", func(t *testing.T) {
+			status, err := adminRequest("POST", "/api/admin/cache/purge", nil, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+		})
+
+		t.Run("SharedCode", func(t *testing.T) {
+			var shared struct {
+				ID string `json:"id"`
+			}
+			if _, err := request("POST", "/api/shared", codeSession("package main"), &shared); err != nil {
+				t.Fatal(err)
+			}
+
+			var export string
+			status, err := adminRequest("GET", "/api/admin/shared/export", nil, &export)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+			if !strings.Contains(export, `"id":"`+shared.ID+`"`) {
+				t.Errorf("expected export to contain shared code %s, got %q", shared.ID, export)
+			}
+
+			status, _ = adminRequest("DELETE", "/api/admin/shared/"+shared.ID, nil, nil)
+			if status != http.StatusNoContent {
+				t.Errorf("expected status %d, got %d", http.StatusNoContent, status)
+			}
+			status, _ = request("GET", "/api/shared/"+shared.ID, nil, nil)
+			if status != http.StatusNotFound {
+				t.Errorf("expected status %d, got %d", http.StatusNotFound, status)
+			}
+
+			var res struct {
+				Imported int `json:"imported"`
+			}
+			status, err = adminRequest("POST", "/api/admin/shared/import", export, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
+			}
+			if res.Imported == 0 {
+				t.Errorf("expected shared code to be imported")
+			}
+			var session sharedSessionResponse
+			status, _ = request("GET", "/api/shared/"+shared.ID, nil, &session)
+			if status != http.StatusOK || len(session.Tabs) != 1 || session.Tabs[0].Code != "package main" {
+				t.Errorf("expected imported shared code, got status %d and %+v", status, session)
+			}
+		})
+
+		t.Run("SharedCodeOverBodyLimit", func(t *testing.T) {
+			const snippets, snippetSize = 80, 50 << 10
+			for i := range snippets {
+				code := fmt.Sprintf("package main\n\n// %d\n", i) + strings.Repeat("// padding\n", snippetSize/11)
+				if status, err := request("POST", "/api/shared", codeSession(code), nil); err != nil || status != http.StatusOK {
+					t.Fatalf("expected code to be shared, got status %d and %v", status, err)
+				}
+			}
+
+			var export string
+			status, err := adminRequest("GET", "/api/admin/shared/export", nil, &export)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Default body limit of the server.
+			if status != http.StatusOK || len(export) <= 4<<20 {
+				t.Fatalf("expected export over the body limit, got status %d and %d bytes", status, len(export))
+			}
+
+			var res struct {
+				Imported int `json:"imported"`
+			}
+			status, err = adminRequest("POST", "/api/admin/shared/import", export, &res)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK || res.Imported < snippets {
+				t.Errorf("expected at least %d imported snippets, got status %d and %d", snippets, status, res.Imported)
+			}
+
+			// Only admins are exempt from the body limit.
+			if status, _ := request("POST", "/api/admin/shared/import", export, nil); status != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, status)
+			}
+		})
+
+		t.Run("SlowSharedCodeImport", func(t *testing.T) {
+			var export string
+			if status, err := adminRequest("GET", "/api/admin/shared/export", nil, &export); err != nil || status != http.StatusOK {
+				t.Fatalf("expected export, got status %d and %v", status, err)
+			}
+			// Import takes longer than the read timeout of the server.
+			body, w := io.Pipe()
+			go func() {
+				first, rest, _ := strings.Cut(export, "\n")
+				_, _ = io.WriteString(w, first+"\n")
+				time.Sleep(4 * time.Second)
+				_, _ = io.WriteString(w, rest)
+				_ = w.Close()
+			}()
+			status, err := adminRequest("POST", "/api/admin/shared/import", body, nil)
+			if err != nil {
+				t.Error(err)
+			}
+			if status != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, status)
 			}
 		})
 	})
 }
 
+// codeSession returns a shared session with a single code tab.
+func codeSession(code string) map[string]any {
+	return map[string]any{"tabs": []map[string]any{{"name": "main.go", "type": "code", "code": code}}}
+}
+
+type sharedSessionResponse struct {
+	Version int `json:"version"`
+	Tabs    []struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Code     string `json:"code"`
+		Settings struct {
+			Compiler struct {
+				Name         string `json:"name"`
+				Architecture string `json:"architecture"`
+			} `json:"compiler"`
+		} `json:"settings"`
+		OriginalSource *int `json:"originalSource"`
+	} `json:"tabs"`
+	ActiveTab int `json:"activeTab"`
+}
+
 func request(method, path string, req, res any) (int, error) {
+	return requestWithHeaders(method, path, nil, req, res)
+}
+
+func adminRequest(method, path string, req, res any) (int, error) {
+	return requestWithHeaders(method, path, map[string]string{"Authorization": "Bearer " + adminToken}, req, res)
+}
+
+func requestWithHeaders(method, path string, headers map[string]string, req, res any) (int, error) {
 	const base = "http://localhost:9000"
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 	var body io.Reader
 	if req != nil {
 		switch req := req.(type) {
+		case io.Reader:
+			body = req
 		case string:
 			buf := []byte(req)
 			body = bytes.NewReader(buf)
@@ -208,6 +759,9 @@ func request(method, path string, req, res any) (int, error) {
 	if body != nil {
 		httpReq.Header.Set("Content-Type", "application/json")
 	}
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
 	httpRes, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		return 0, fmt.Errorf("do request: %w", err)
@@ -255,7 +809,8 @@ func startGoce(t *testing.T, binary string) *exec.Cmd {
 	cmd.Dir = filepath.Dir(binary)
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env,
-		"GOCE_CACHE_ENABLED=false",
+		"GOCE_CACHE_ENABLED=true",
+		"GOCE_ADMIN_TOKEN="+adminToken,
 		"GOCE_COMPILERS_ADDITIONAL_ARCHITECTURES=false",
 	)
 	cmd.Stderr = os.Stderr
@@ -268,6 +823,8 @@ func startGoce(t *testing.T, binary string) *exec.Cmd {
 	return cmd
 }
 
+const adminToken = "test-admin-token"
+
 func stopGoce(t *testing.T, cmd *exec.Cmd) {
 	t.Helper()
 	if err := cmd.Process.Signal(os.Interrupt); err != nil {
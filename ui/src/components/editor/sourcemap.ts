@@ -145,6 +145,18 @@ export class SourceMap {
             } else {
               decoration.options.hoverMessage.push({ value: d.reason })
             }
+            if (d.budget && d.cost) {
+              const distance = d.budget - d.cost
+              decoration.options.hoverMessage.push({
+                value: distance >= 0 ? `${distance} under budget` : `${-distance} over budget`,
+              })
+            }
+            if (d.breakdown?.length) {
+              const costliest = [...d.breakdown].sort((a, b) => b.cost - a.cost).slice(0, 3)
+              decoration.options.hoverMessage.push({
+                value: costliest.map((c) => `line ${c.range.s.l}: cost ${c.cost}`).join('  \n'),
+              })
+            }
             decs.push(decoration)
             break
           }
@@ -184,6 +196,104 @@ export class SourceMap {
             break
           }
 
+          case 'devirtualization': {
+            const call = d.call ? `\`${d.call}\`` : 'interface method call'
+            const message =
+              d.kind === 'dynamic'
+                ? `${call} is dispatched dynamically`
+                : `${d.kind === 'pgo' ? 'PGO ' : ''}devirtualized ${call} to \`${d.target}\``
+            decs.push({
+              range,
+              options: {
+                hoverMessage: { value: message },
+                inlineClassName: 'inline-hover-devirtualization',
+              },
+            })
+            break
+          }
+
+          case 'closureCapture': {
+            decs.push({
+              range,
+              options: {
+                hoverMessage: [
+                  { value: `\`${d.name}\` captured by ${d.mode} in \`${d.function}\`` },
+                  { value: `addr: ${d.addr}, assign: ${d.assign}, width: ${d.width}` },
+                ],
+                inlineClassName: 'inline-hover-capture',
+              },
+            })
+            break
+          }
+
+          case 'funcLiteral': {
+            decs.push({
+              range,
+              options: {
+                hoverMessage: {
+                  value: d.escapes ? 'func literal escapes to heap' : 'func literal does not escape',
+                },
+                inlineClassName: d.escapes ? 'inline-hover-escape' : 'inline-hover-can-inline',
+              },
+            })
+            break
+          }
+
+          case 'spill': {
+            decs.push({
+              range,
+              options: {
+                hoverMessage: {
+                  value: `\`${d.name}\` spilled to stack across call to \`${d.call}\``,
+                },
+                inlineClassName: 'inline-hover-spill',
+              },
+            })
+            break
+          }
+
+          case 'prove': {
+            const message =
+              d.kind === 'inductionVariable'
+                ? `induction variable: limits ${d.limits}, increment ${d.increment}`
+                : `${d.kind} \`${d.op}\``
+            decs.push({
+              range,
+              options: {
+                hoverMessage: { value: message },
+                inlineClassName: 'inline-hover-prove',
+              },
+            })
+            break
+          }
+
+          case 'writeBarrier': {
+            decs.push({
+              range,
+              options: {
+                hoverMessage: [
+                  { value: `pointer store behind write barrier in \`${d.functions.join('`, `')}\`` },
+                  { value: `flag checks: ${d.checks}, barrier calls: ${d.calls}` },
+                ],
+                inlineClassName: 'inline-hover-write-barrier',
+              },
+            })
+            break
+          }
+
+          case 'stackCheck': {
+            decs.push({
+              range,
+              options: {
+                hoverMessage: {
+                  value: `stack check in prologue, frame ${d.frame} bytes, calls \`${d.morestack}\``,
+                },
+                inlineClassName: 'inline-hover-stack-check',
+              },
+            })
+            break
+          }
+
         }
       }
     }
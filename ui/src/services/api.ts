@@ -107,7 +107,18 @@ export interface CompilationResult {
   diagnostics?: Diagnostic[]
 }
 
-type Diagnostic = InliningAnalysis | InlinedCall | HeapEscape | BoundsCheck
+type Diagnostic =
+  | InliningAnalysis
+  | InlinedCall
+  | HeapEscape
+  | BoundsCheck
+  | Devirtualization
+  | ClosureCapture
+  | FuncLiteral
+  | Spill
+  | Prove
+  | WriteBarrier
+  | StackCheck
 
 interface InliningAnalysis {
   type: 'inliningAnalysis'
@@ -116,6 +127,8 @@ interface InliningAnalysis {
   canInline: boolean
   reason: string
   cost: number
+  budget?: number
+  breakdown?: { range: FileRange; cost: number }[]
 }
 
 interface InlinedCall {
@@ -130,6 +143,7 @@ interface HeapEscape {
   range: FileRange
   name?: string
   message?: string
+  function?: string
 }
 
 interface BoundsCheck {
@@ -137,6 +151,65 @@ interface BoundsCheck {
   range: FileRange
 }
 
+interface Devirtualization {
+  type: 'devirtualization'
+  range: FileRange
+  kind: 'static' | 'pgo' | 'dynamic'
+  call?: string
+  target?: string
+}
+
+interface ClosureCapture {
+  type: 'closureCapture'
+  range: FileRange
+  name: string
+  function: string
+  mode: 'value' | 'ref'
+  addr: boolean
+  assign: boolean
+  width: number
+}
+
+interface FuncLiteral {
+  type: 'funcLiteral'
+  range: FileRange
+  escapes: boolean
+}
+
+interface Prove {
+  type: 'prove'
+  range: FileRange
+  kind: 'proved' | 'disproved' | 'inductionVariable'
+  op?: string
+  limits?: string
+  increment?: number
+}
+
+interface Spill {
+  type: 'spill'
+  range: FileRange
+  name: string
+  function: string
+  call: string
+  reloads: number
+}
+
+interface WriteBarrier {
+  type: 'writeBarrier'
+  range: FileRange
+  functions: string[]
+  checks: number
+  calls: number
+}
+
+interface StackCheck {
+  type: 'stackCheck'
+  range: FileRange
+  function: string
+  frame: number
+  morestack: string
+}
+
 interface FileRange {
   s: FileLocation
   e: FileLocation
@@ -165,6 +238,7 @@ export type SharedTab = {
 } & (SharedCodeTab | SharedDiffTab)
 
 export type SharedCode = {
+  version?: number
   tabs: Array<SharedTab>
   activeTab: number
 }